@@ -0,0 +1,299 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Cloner interface {
+	Clone(Env, reflect.Value) reflect.Value
+}
+
+type cloneTag struct{}
+
+// CloneVals starts a new clone: references that are shared within v are
+// shared within the result, but never with the results of other calls.
+func CloneVals(env Env, v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		panic(ErrInvalid)
+	}
+	env, s := withState(env)
+	outer := s.clones
+	s.clones = nil
+	defer func() {
+		s.clones = outer
+	}()
+	return cloneVal(env, v)
+}
+
+func Clone[T any](env Env, in T) T {
+	v := ValueFor(in)
+	var out T
+	reflect.ValueOf(&out).Elem().Set(CloneVals(env, v))
+	return out
+}
+
+func TryCloneVals(env Env, v reflect.Value) (reflect.Value, error) {
+	var result reflect.Value
+	err := try(func() {
+		result = CloneVals(env, v)
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return result, nil
+}
+
+func TryClone[T any](env Env, in T) (T, error) {
+	var result T
+	err := try(func() {
+		result = Clone(env, in)
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+func cloneVal(env Env, v reflect.Value) reflect.Value {
+	impl := func() Cloner {
+		if env == nil {
+			return CloneDefault{}
+		}
+		anyVal, ok := env.Get(v.Type(), cloneTag{})
+		if !ok {
+			return CloneDefault{}
+		}
+		impl := anyVal.(Cloner)
+		if impl == nil {
+			return CloneDefault{}
+		}
+		return impl
+	}()
+	return impl.Clone(env, v)
+}
+
+// cloneRef returns the clone of v if one was already made during the current
+// clone.  Otherwise it records the clone that newVal returns, before the
+// caller fills it in, so that cycles through v resolve to the new value.
+func cloneRef(env Env, v reflect.Value, newVal func() reflect.Value) (Env, reflect.Value, bool) {
+	env, s := withState(env)
+	key, ok := visitKeyFor(v, v)
+	if !ok {
+		return env, newVal(), false
+	}
+	if out, found := s.clones[key]; found {
+		return env, out, true
+	}
+	if s.clones == nil {
+		s.clones = make(map[visitKey]reflect.Value)
+	}
+	out := newVal()
+	s.clones[key] = out
+	return env, out, false
+}
+
+type CloneFunc func(Env, reflect.Value) reflect.Value
+
+func (f CloneFunc) Clone(env Env, v reflect.Value) reflect.Value {
+	return f(env, v)
+}
+
+// CloneShallow returns the value as-is, so any references it contains are
+// shared with the original.
+type CloneShallow struct{}
+
+func (CloneShallow) Clone(_ Env, v reflect.Value) reflect.Value {
+	return v
+}
+
+type CloneDeep struct{}
+
+func (CloneDeep) Clone(env Env, v reflect.Value) reflect.Value {
+	return cloneVal(env, v)
+}
+
+type CloneDefault struct{}
+
+func (CloneDefault) Clone(env Env, v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		return CloneStruct{}.Clone(env, v)
+	case reflect.Map:
+		return CloneMap{}.Clone(env, v)
+	case reflect.Slice, reflect.Array:
+		return CloneSlice{}.Clone(env, v)
+	case reflect.Pointer:
+		return ClonePointer{}.Clone(env, v)
+	case reflect.Interface:
+		return CloneInterface{}.Clone(env, v)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer,
+		reflect.Complex128, reflect.Complex64,
+		reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.String, reflect.Bool:
+		return CloneShallow{}.Clone(env, v)
+	default:
+		panic(fmt.Errorf("%w: unsupported kind %v for value %v", ErrInternal, v.Kind(), v))
+	}
+}
+
+type CloneStruct struct {
+	Fields map[Field]Cloner
+}
+
+// Unexported fields are copied as-is.
+func (cs CloneStruct) Clone(env Env, v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Kind() != reflect.Struct {
+		panic(ErrWrongType)
+	}
+	for f := range cs.Fields {
+		if f == nil {
+			panic(ErrNilField)
+		}
+	}
+	out := reflect.New(t).Elem()
+	out.Set(v)
+	for fNum := range t.NumField() {
+		f := t.Field(fNum)
+		if !f.IsExported() {
+			continue
+		}
+		impl, ok := cs.Fields[fieldKey(f)]
+		if !ok || impl == nil {
+			impl = CloneDeep{}
+		}
+		out.Field(fNum).Set(impl.Clone(env, v.Field(fNum)))
+	}
+	return out
+}
+
+type CloneSlice struct {
+	Elems Cloner
+}
+
+func (cs CloneSlice) Clone(env Env, v reflect.Value) reflect.Value {
+	t := v.Type()
+	var out reflect.Value
+	switch t.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		var done bool
+		env, out, done = cloneRef(env, v, func() reflect.Value {
+			return reflect.MakeSlice(t, v.Len(), v.Cap())
+		})
+		if done {
+			return out
+		}
+	case reflect.Array:
+		out = reflect.New(t).Elem()
+	default:
+		panic(ErrWrongType)
+	}
+	elems := cs.Elems
+	if elems == nil {
+		elems = CloneDeep{}
+	}
+	for i := range v.Len() {
+		out.Index(i).Set(elems.Clone(env, v.Index(i)))
+	}
+	return out
+}
+
+type CloneMap struct {
+	Keys Cloner
+	Vals Cloner
+}
+
+func (cm CloneMap) Clone(env Env, v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Kind() != reflect.Map {
+		panic(ErrWrongType)
+	}
+	if v.IsNil() {
+		return reflect.Zero(t)
+	}
+	env, out, done := cloneRef(env, v, func() reflect.Value {
+		return reflect.MakeMapWithSize(t, v.Len())
+	})
+	if done {
+		return out
+	}
+	keys := cm.Keys
+	if keys == nil {
+		keys = CloneDeep{}
+	}
+	vals := cm.Vals
+	if vals == nil {
+		vals = CloneDeep{}
+	}
+	i := v.MapRange()
+	for i.Next() {
+		out.SetMapIndex(keys.Clone(env, i.Key()), vals.Clone(env, i.Value()))
+	}
+	return out
+}
+
+type ClonePointer struct {
+	Elem Cloner
+}
+
+func (cp ClonePointer) Clone(env Env, v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Kind() != reflect.Pointer {
+		panic(ErrWrongType)
+	}
+	if v.IsNil() {
+		return reflect.Zero(t)
+	}
+	env, out, done := cloneRef(env, v, func() reflect.Value {
+		return reflect.New(t.Elem())
+	})
+	if done {
+		return out
+	}
+	elem := cp.Elem
+	if elem == nil {
+		elem = CloneDeep{}
+	}
+	out.Elem().Set(elem.Clone(env, v.Elem()))
+	return out
+}
+
+type CloneInterface struct {
+	Elem Cloner
+}
+
+func (ci CloneInterface) Clone(env Env, v reflect.Value) reflect.Value {
+	t := v.Type()
+	if t.Kind() != reflect.Interface {
+		panic(ErrWrongType)
+	}
+	out := reflect.New(t).Elem()
+	if v.IsNil() {
+		return out
+	}
+	elem := ci.Elem
+	if elem == nil {
+		elem = CloneDeep{}
+	}
+	out.Set(elem.Clone(env, v.Elem()))
+	return out
+}
+
+func CloneOpt(t reflect.Type, cloner Cloner) Opt {
+	return OptFunc(func(env Env) {
+		env.Set(t, cloneTag{}, cloner)
+	})
+}
+
+func CloneOptAll(cloner Cloner) Opt {
+	return OptFunc(func(env Env) {
+		env.SetAll(cloneTag{}, cloner)
+	})
+}
@@ -0,0 +1,86 @@
+package ops_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+func TestClone(t *testing.T) {
+	type Shared struct {
+		Vals []int
+	}
+	type Doc struct {
+		Name   string
+		Tags   map[string][]string
+		A, B   *Shared
+		Any    any
+		Cached *Shared
+		secret int
+	}
+	newDoc := func() Doc {
+		s := &Shared{Vals: []int{1, 2}}
+		return Doc{
+			Name:   "doc",
+			Tags:   map[string][]string{"k": {"v1", "v2"}},
+			A:      s,
+			B:      s,
+			Any:    []int{3},
+			Cached: &Shared{Vals: []int{4}},
+			secret: 42,
+		}
+	}
+	env := ops.WrapEnv(ops.NewEnv(), ops.CloneOpt(reflect.TypeFor[Doc](), ops.CloneStruct{
+		Fields: map[ops.Field]ops.Cloner{
+			ops.NamedField("Cached"): ops.CloneShallow{},
+		},
+	}))
+
+	orig := newDoc()
+	c := ops.Clone(env, orig)
+
+	if !ops.Equal(env, orig, c) {
+		t.Fatalf("clone is not equal to original:\n%s", ops.Diff(env, orig, c))
+	}
+	if c.A == orig.A {
+		t.Error("pointer was not cloned")
+	}
+	if c.A != c.B {
+		t.Error("aliasing between pointers was not preserved")
+	}
+	if c.Cached != orig.Cached {
+		t.Error("field override was not honored")
+	}
+	if c.secret != orig.secret {
+		t.Error("unexported field was not copied")
+	}
+
+	c.A.Vals[0] = 100
+	c.Tags["k"][0] = "changed"
+	c.Any.([]int)[0] = 100
+	if !ops.Equal(env, orig, newDoc()) {
+		t.Errorf("modifying clone changed original:\n%s", ops.Diff(env, orig, newDoc()))
+	}
+
+	t.Run("Cycle", func(t *testing.T) {
+		r := newRing(1, 2, 3)
+		c := ops.Clone(nil, r)
+		if c == r || c.Next == r.Next {
+			t.Error("ring was not cloned")
+		}
+		if c.Next.Next.Next != c || c.Prev.Next != c {
+			t.Error("cycle was not preserved")
+		}
+		if !ops.Equal(nil, r, c) {
+			t.Error("clone is not equal to original")
+		}
+	})
+
+	t.Run("Nil Interface", func(t *testing.T) {
+		var in any
+		if got := ops.Clone(nil, in); got != nil {
+			t.Errorf("got %v, want nil", got)
+		}
+	})
+}
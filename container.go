@@ -0,0 +1,154 @@
+package ops
+
+import "iter"
+
+// Map is a hash map whose keys are compared with Equal and hashed with Hash
+// under its Env, so keys don't need to be Go-comparable.  Keys must not be
+// modified while they are in the map.  The zero value is an empty map that
+// uses the default Env.
+type Map[K, V any] struct {
+	env     Env
+	buckets map[uint64][]mapEntry[K, V]
+	len     int
+}
+
+type mapEntry[K, V any] struct {
+	key K
+	val V
+}
+
+func NewMap[K, V any](env Env) *Map[K, V] {
+	return &Map[K, V]{env: env}
+}
+
+func (m *Map[K, V]) find(key K) (uint64, int) {
+	h := Hash(m.env, key)
+	for idx, e := range m.buckets[h] {
+		if Equal(m.env, e.key, key) {
+			return h, idx
+		}
+	}
+	return h, -1
+}
+
+func (m *Map[K, V]) Put(key K, val V) {
+	h, idx := m.find(key)
+	if idx >= 0 {
+		m.buckets[h][idx].val = val
+		return
+	}
+	m.insert(h, key, val)
+}
+
+func (m *Map[K, V]) insert(h uint64, key K, val V) {
+	if m.buckets == nil {
+		m.buckets = make(map[uint64][]mapEntry[K, V])
+	}
+	m.buckets[h] = append(m.buckets[h], mapEntry[K, V]{key: key, val: val})
+	m.len++
+}
+
+func (m *Map[K, V]) Get(key K) (V, bool) {
+	h, idx := m.find(key)
+	if idx < 0 {
+		var zero V
+		return zero, false
+	}
+	return m.buckets[h][idx].val, true
+}
+
+func (m *Map[K, V]) Has(key K) bool {
+	_, idx := m.find(key)
+	return idx >= 0
+}
+
+func (m *Map[K, V]) Delete(key K) bool {
+	h, idx := m.find(key)
+	if idx < 0 {
+		return false
+	}
+	bucket := m.buckets[h]
+	last := len(bucket) - 1
+	bucket[idx] = bucket[last]
+	bucket[last] = mapEntry[K, V]{}
+	if last == 0 {
+		delete(m.buckets, h)
+	} else {
+		m.buckets[h] = bucket[:last]
+	}
+	m.len--
+	return true
+}
+
+func (m *Map[K, V]) Len() int {
+	return m.len
+}
+
+func (m *Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, bucket := range m.buckets {
+			for _, e := range bucket {
+				if !yield(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (m *Map[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range m.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Map[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range m.All() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Set is a hash set whose elements are compared with Equal and hashed with
+// Hash under its Env.  The zero value is an empty set that uses the default
+// Env.
+type Set[T any] struct {
+	m Map[T, struct{}]
+}
+
+func NewSet[T any](env Env) *Set[T] {
+	return &Set[T]{m: Map[T, struct{}]{env: env}}
+}
+
+// Add returns false if an equal element was already present.
+func (s *Set[T]) Add(v T) bool {
+	h, idx := s.m.find(v)
+	if idx >= 0 {
+		return false
+	}
+	s.m.insert(h, v, struct{}{})
+	return true
+}
+
+func (s *Set[T]) Has(v T) bool {
+	return s.m.Has(v)
+}
+
+func (s *Set[T]) Delete(v T) bool {
+	return s.m.Delete(v)
+}
+
+func (s *Set[T]) Len() int {
+	return s.m.Len()
+}
+
+func (s *Set[T]) All() iter.Seq[T] {
+	return s.m.Keys()
+}
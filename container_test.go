@@ -0,0 +1,88 @@
+package ops_test
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+type containerKey struct {
+	Name    string
+	Parts   []int
+	Comment string
+}
+
+func TestSet(t *testing.T) {
+	env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[containerKey](), ops.EqStruct{
+		Fields: map[ops.Field]ops.Eq{
+			ops.NamedField("Comment"): ops.EqTrue{},
+		},
+	}))
+	s := ops.NewSet[containerKey](env)
+
+	if !s.Add(containerKey{Name: "a", Parts: []int{1, 2}, Comment: "first"}) {
+		t.Error("Add() of new element returned false")
+	}
+	if s.Add(containerKey{Name: "a", Parts: []int{1, 2}, Comment: "second"}) {
+		t.Error("Add() of equal element returned true")
+	}
+	if !s.Add(containerKey{Name: "a", Parts: []int{1, 3}}) {
+		t.Error("Add() of new element returned false")
+	}
+	if got := s.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if !s.Has(containerKey{Name: "a", Parts: []int{1, 3}, Comment: "ignored"}) {
+		t.Error("Has() = false, want true")
+	}
+	var comments []string
+	for k := range s.All() {
+		comments = append(comments, k.Comment)
+	}
+	slices.Sort(comments)
+	if want := []string{"", "first"}; !slices.Equal(comments, want) {
+		t.Errorf("All() yielded comments %q, want %q", comments, want)
+	}
+	if !s.Delete(containerKey{Name: "a", Parts: []int{1, 2}}) {
+		t.Error("Delete() of present element returned false")
+	}
+	if s.Delete(containerKey{Name: "a", Parts: []int{1, 2}}) {
+		t.Error("Delete() of missing element returned true")
+	}
+	if got := s.Len(); got != 1 {
+		t.Errorf("Len() = %d, want 1", got)
+	}
+}
+
+func TestMap(t *testing.T) {
+	var m ops.Map[[]string, int]
+	m.Put([]string{"a"}, 1)
+	m.Put([]string{"a", "b"}, 2)
+	m.Put([]string{"a"}, 3)
+
+	if got := m.Len(); got != 2 {
+		t.Errorf("Len() = %d, want 2", got)
+	}
+	if got, ok := m.Get([]string{"a"}); !ok || got != 3 {
+		t.Errorf("Get() = %d, %v, want 3, true", got, ok)
+	}
+	if _, ok := m.Get([]string{"b"}); ok {
+		t.Error("Get() of missing key returned true")
+	}
+	var vals []int
+	for v := range m.Values() {
+		vals = append(vals, v)
+	}
+	slices.Sort(vals)
+	if want := []int{2, 3}; !slices.Equal(vals, want) {
+		t.Errorf("Values() = %v, want %v", vals, want)
+	}
+	if !m.Delete([]string{"a", "b"}) {
+		t.Error("Delete() of present key returned false")
+	}
+	if m.Has([]string{"a", "b"}) {
+		t.Error("Has() of deleted key returned true")
+	}
+}
@@ -0,0 +1,387 @@
+package ops
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Differ is implemented by Eqs that can report where two values differ.  Eq
+// implementations that don't also implement Differ are reported as a single
+// difference whenever they return false.
+type Differ interface {
+	Diff(Env, reflect.Value, reflect.Value) *DiffNode
+}
+
+// DiffNode describes where two values differ.  Step is the path element
+// leading from the parent (e.g. ".Foo", "[3]", or `["key"]`).  V1 or V2 is
+// invalid if the location only exists on one side.
+type DiffNode struct {
+	Step     string
+	V1, V2   reflect.Value
+	Children []*DiffNode
+	// The Env that the diff was made with, for String.
+	env Env
+}
+
+func (d *DiffNode) Leaves() iter.Seq2[string, *DiffNode] {
+	return func(yield func(string, *DiffNode) bool) {
+		d.leaves("", yield)
+	}
+}
+
+func (d *DiffNode) leaves(prefix string, yield func(string, *DiffNode) bool) bool {
+	if d == nil {
+		return true
+	}
+	path := prefix + d.Step
+	if len(d.Children) == 0 {
+		return yield(path, d)
+	}
+	for _, child := range d.Children {
+		if !child.leaves(path, yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes each difference on its own line, formatting values with
+// the Env that the diff was made with.
+func (d *DiffNode) String() string {
+	if d == nil {
+		return "<no diff>"
+	}
+	return d.Format(d.env)
+}
+
+// Format is like String, but formats values with env.  Values are kept on one
+// line, and path options apply to them as they would if the root value were
+// being formatted.
+func (d *DiffNode) Format(env Env) string {
+	if d == nil {
+		return "<no diff>"
+	}
+	if env == nil {
+		env = NewEnv()
+	}
+	var lines []string
+	d.format(WrapEnv(env, FmtOptCompact()), nil, &lines)
+	return strings.Join(lines, "\n")
+}
+
+func (d *DiffNode) format(env Env, steps []string, lines *[]string) {
+	if d.Step != "" {
+		steps = append(steps, d.Step)
+	}
+	if len(d.Children) > 0 {
+		for _, child := range d.Children {
+			child.format(env, steps, lines)
+		}
+		return
+	}
+	env, impl := fmtPathEnv(env, steps)
+	side := func(v reflect.Value) string {
+		if !v.IsValid() {
+			return "<missing>"
+		}
+		impl := impl
+		if impl == nil {
+			impl = fmtFor(env, v.Type())
+		}
+		return RenderDoc(env, fmtDoc(env, impl, v))
+	}
+	path := strings.Join(steps, "")
+	if path == "" {
+		path = "<root>"
+	}
+	*lines = append(*lines, fmt.Sprintf("%s: %s != %s", path, side(d.V1), side(d.V2)))
+}
+
+func DiffVals(env Env, v1, v2 reflect.Value) *DiffNode {
+	if !v1.IsValid() || !v2.IsValid() {
+		panic(ErrInvalid)
+	}
+	typ := v1.Type()
+	if typ != v2.Type() {
+		panic(ErrWrongType)
+	}
+	d := diffWith(env, eqFor(env, typ), v1, v2)
+	if d != nil && d.env == nil {
+		d.env = env
+	}
+	return d
+}
+
+func Diff[T any](env Env, in1, in2 T) *DiffNode {
+	v1 := ValueFor(in1)
+	v2 := ValueFor(in2)
+	return DiffVals(env, v1, v2)
+}
+
+func TryDiffVals(env Env, v1, v2 reflect.Value) (*DiffNode, error) {
+	var result *DiffNode
+	err := try(func() {
+		result = DiffVals(env, v1, v2)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func TryDiff[T any](env Env, in1, in2 T) (*DiffNode, error) {
+	var result *DiffNode
+	err := try(func() {
+		result = Diff(env, in1, in2)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func diffWith(env Env, eq Eq, v1, v2 reflect.Value) *DiffNode {
+	if differ, ok := eq.(Differ); ok {
+		return differ.Diff(env, v1, v2)
+	}
+	if eq.Eq(env, v1, v2) {
+		return nil
+	}
+	return &DiffNode{V1: v1, V2: v2}
+}
+
+func (EqDefault) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if useEqMethod(env, v1.Type()) && v1.CanInterface() && v2.CanInterface() {
+		return diffWith(env, EqMethod{}, v1, v2)
+	}
+	switch v1.Kind() {
+	case reflect.Struct:
+		return EqStruct{}.Diff(env, v1, v2)
+	case reflect.Map:
+		return EqMap{}.Diff(env, v1, v2)
+	case reflect.Slice, reflect.Array:
+		return EqSlice{}.Diff(env, v1, v2)
+	case reflect.Ptr:
+		return EqPointer{}.Diff(env, v1, v2)
+	case reflect.Interface:
+		return EqInterface{}.Diff(env, v1, v2)
+	default:
+		return diffWith(env, EqOptFunc(EqDefault{}.Eq), v1, v2)
+	}
+}
+
+func (EqDeep) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	return DiffVals(env, v1, v2)
+}
+
+func (cp EqPointer) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if v1.Kind() != reflect.Ptr {
+		panic(ErrWrongType)
+	}
+	if cp.ByAddr || v1.IsNil() || v2.IsNil() {
+		return diffWith(env, EqOptFunc(cp.Eq), v1, v2)
+	}
+	env, s := withState(env)
+	if _, ok := s.diff.enter(v1, v2); !ok {
+		return nil
+	}
+	defer s.diff.leave(v1, v2)
+	elem := cp.Elem
+	if elem == nil {
+		elem = EqDeep{}
+	}
+	return diffWith(env, elem, v1.Elem(), v2.Elem())
+}
+
+func (ci EqInterface) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if v1.Kind() != reflect.Interface {
+		panic(ErrWrongType)
+	}
+	if v1.IsNil() || v2.IsNil() || v1.Elem().Type() != v2.Elem().Type() {
+		return diffWith(env, EqOptFunc(ci.Eq), v1, v2)
+	}
+	elem := ci.Elem
+	if elem == nil {
+		elem = EqDeep{}
+	}
+	return diffWith(env, elem, v1.Elem(), v2.Elem())
+}
+
+func (cs EqStruct) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	t := v1.Type()
+	if t.Kind() != reflect.Struct {
+		panic(ErrWrongType)
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	var children []*DiffNode
+	var fieldName string
+	defer annotateField(t, &fieldName)
+	for f, impl := range cs.fields(t) {
+		fieldName = f.Name
+		impl = pathChild(paths, fieldPath(f.Name), impl)
+		if child := diffWith(env, impl, v1.Field(f.Index[0]), v2.Field(f.Index[0])); child != nil {
+			child.Step = fieldStep(f.Name)
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return &DiffNode{V1: v1, V2: v2, Children: children}
+}
+
+func (cs EqSlice) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	switch v1.Kind() {
+	case reflect.Slice, reflect.Array:
+		// ok
+	default:
+		panic(ErrWrongType)
+	}
+	if v1.Kind() == reflect.Slice && v1.IsNil() != v2.IsNil() {
+		return &DiffNode{V1: v1, V2: v2}
+	}
+	env, s := withState(env)
+	if _, ok := s.diff.enter(v1, v2); !ok {
+		return nil
+	}
+	defer s.diff.leave(v1, v2)
+	elems := cs.Elems
+	if elems == nil {
+		elems = EqDeep{}
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	var children []*DiffNode
+	if cs.Unordered {
+		unmatched1, unmatched2 := matchUnordered(env, paths, elems, v1, v2)
+		for _, i := range unmatched1 {
+			children = append(children, &DiffNode{Step: indexStep(i), V1: v1.Index(i)})
+		}
+		for _, i := range unmatched2 {
+			children = append(children, &DiffNode{Step: indexStep(i), V2: v2.Index(i)})
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		return &DiffNode{V1: v1, V2: v2, Children: children}
+	}
+	idx := -1
+	defer annotateIndex(v1.Type(), &idx)
+	for elemNum := range max(v1.Len(), v2.Len()) {
+		idx = elemNum
+		var child *DiffNode
+		switch {
+		case elemNum >= v1.Len():
+			child = &DiffNode{V2: v2.Index(elemNum)}
+		case elemNum >= v2.Len():
+			child = &DiffNode{V1: v1.Index(elemNum)}
+		default:
+			impl := pathChild(paths, indexPath(elemNum), elems)
+			child = diffWith(env, impl, v1.Index(elemNum), v2.Index(elemNum))
+		}
+		if child != nil {
+			child.Step = indexStep(elemNum)
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	return &DiffNode{V1: v1, V2: v2, Children: children}
+}
+
+func (cm EqMap) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if v1.Kind() != reflect.Map {
+		panic(ErrWrongType)
+	}
+	// Keys are matched one at a time below, which can disagree with the
+	// all-at-once matching done by Eq when a custom key comparison considers
+	// several distinct keys equal.  Checking Eq first keeps the two consistent.
+	if cm.Eq(env, v1, v2) {
+		return nil
+	}
+	if v1.IsNil() != v2.IsNil() {
+		return &DiffNode{V1: v1, V2: v2}
+	}
+	env, s := withState(env)
+	if _, ok := s.diff.enter(v1, v2); !ok {
+		return nil
+	}
+	defer s.diff.leave(v1, v2)
+	keys := cm.Keys
+	if keys == nil {
+		keys = EqDeep{}
+	}
+	vals := cm.Vals
+	if vals == nil {
+		vals = EqDeep{}
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+
+	var children []*DiffNode
+	var key reflect.Value
+	defer annotateKey(env, v1.Type(), &key)
+	if isBuiltinEq(env, cm.Keys, v1.Type().Key()) {
+		i := v1.MapRange()
+		for i.Next() {
+			key = i.Key()
+			var child *DiffNode
+			if val2 := v2.MapIndex(key); val2.IsValid() {
+				child = diffWith(env, pathChild(paths, keyPath(key), vals), i.Value(), val2)
+			} else {
+				child = &DiffNode{V1: i.Value()}
+			}
+			if child != nil {
+				child.Step = keyStep(env, key)
+				children = append(children, child)
+			}
+		}
+		i = v2.MapRange()
+		for i.Next() {
+			key = i.Key()
+			if !v1.MapIndex(key).IsValid() {
+				children = append(children, &DiffNode{Step: keyStep(env, key), V2: i.Value()})
+			}
+		}
+	} else {
+		keys2 := v2.MapKeys()
+		used := make([]bool, len(keys2))
+		i := v1.MapRange()
+	k1loop:
+		for i.Next() {
+			key = i.Key()
+			for idx, k2 := range keys2 {
+				paths.skip()
+				if used[idx] || !keys.Eq(env, key, k2) {
+					continue
+				}
+				used[idx] = true
+				impl := pathChild(paths, keyPath(key), vals)
+				if child := diffWith(env, impl, i.Value(), v2.MapIndex(k2)); child != nil {
+					child.Step = keyStep(env, key)
+					children = append(children, child)
+				}
+				continue k1loop
+			}
+			children = append(children, &DiffNode{Step: keyStep(env, key), V1: i.Value()})
+		}
+		for idx, k2 := range keys2 {
+			key = k2
+			if !used[idx] {
+				children = append(children, &DiffNode{Step: keyStep(env, k2), V2: v2.MapIndex(k2)})
+			}
+		}
+	}
+	if len(children) == 0 {
+		return &DiffNode{V1: v1, V2: v2}
+	}
+	slices.SortFunc(children, func(a, b *DiffNode) int {
+		return strings.Compare(a.Step, b.Step)
+	})
+	return &DiffNode{V1: v1, V2: v2, Children: children}
+}
@@ -0,0 +1,205 @@
+package ops_test
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+func TestDiff(t *testing.T) {
+	type Inner struct {
+		Tags map[string]int
+	}
+	type Outer struct {
+		Name  string
+		Items []Inner
+		Ptr   *Inner
+		Any   any
+	}
+	base := func() Outer {
+		return Outer{
+			Name:  "base",
+			Items: []Inner{{Tags: map[string]int{"a": 1}}, {Tags: map[string]int{"b": 2}}},
+			Ptr:   &Inner{Tags: map[string]int{"c": 3}},
+			Any:   1,
+		}
+	}
+	tests := []struct {
+		name      string
+		env       ops.Env
+		change    func(*Outer)
+		wantPaths []string
+	}{
+		{
+			name:   "Equal",
+			change: func(*Outer) {},
+		},
+		{
+			name:      "Struct Field",
+			change:    func(o *Outer) { o.Name = "other" },
+			wantPaths: []string{".Name"},
+		},
+		{
+			name:      "Map Value In Slice",
+			change:    func(o *Outer) { o.Items[1].Tags["b"] = 3 },
+			wantPaths: []string{`.Items[1].Tags["b"]`},
+		},
+		{
+			name: "Missing And Extra Map Keys",
+			change: func(o *Outer) {
+				delete(o.Items[0].Tags, "a")
+				o.Items[0].Tags["z"] = 1
+			},
+			wantPaths: []string{`.Items[0].Tags["a"]`, `.Items[0].Tags["z"]`},
+		},
+		{
+			name:      "Extra Slice Element",
+			change:    func(o *Outer) { o.Items = append(o.Items, Inner{}) },
+			wantPaths: []string{".Items[2]"},
+		},
+		{
+			name:      "Through Pointer",
+			change:    func(o *Outer) { o.Ptr.Tags["c"] = 4 },
+			wantPaths: []string{`.Ptr.Tags["c"]`},
+		},
+		{
+			name:      "Nil Pointer",
+			change:    func(o *Outer) { o.Ptr = nil },
+			wantPaths: []string{".Ptr"},
+		},
+		{
+			name:      "Interface Dynamic Type",
+			change:    func(o *Outer) { o.Any = "1" },
+			wantPaths: []string{".Any"},
+		},
+		{
+			name: "Custom Field Eq",
+			env: ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[Outer](), ops.EqStruct{
+				Fields: map[ops.Field]ops.Eq{
+					ops.NamedField("Name"): ops.EqTrue{},
+				},
+			})),
+			change: func(o *Outer) { o.Name = "ignored" },
+		},
+		{
+			name: "Custom Type Eq",
+			env: ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[Inner](), ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+				return v1.Field(0).Len() == v2.Field(0).Len()
+			}))),
+			change: func(o *Outer) {
+				o.Items[0].Tags["a"] = 100
+				o.Items[1].Tags["x"] = 1
+			},
+			wantPaths: []string{".Items[1]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1 := base()
+			v2 := base()
+			tt.change(&v2)
+			d := ops.Diff(tt.env, v1, v2)
+			var gotPaths []string
+			for path := range d.Leaves() {
+				gotPaths = append(gotPaths, path)
+			}
+			if !slices.Equal(gotPaths, tt.wantPaths) {
+				t.Errorf("got paths %q, want %q", gotPaths, tt.wantPaths)
+			}
+			if eq := ops.Equal(tt.env, v1, v2); eq != (d == nil) {
+				t.Errorf("Equal() = %v but Diff() = %v", eq, d)
+			}
+		})
+	}
+}
+
+func TestDiffString(t *testing.T) {
+	type Inner struct {
+		A, B []int
+	}
+	type Outer struct {
+		Name  string
+		Inner Inner
+		Keys  map[[2]int]string
+	}
+	v1 := Outer{Name: "x", Inner: Inner{A: []int{1, 2}}, Keys: map[[2]int]string{{1, 2}: "a"}}
+	v2 := Outer{Name: "y", Inner: Inner{A: []int{1, 3}, B: []int{4}}, Keys: map[[2]int]string{{1, 2}: "b"}}
+	tests := []struct {
+		name string
+		v1   any
+		v2   any
+		env  ops.Env
+		want string
+	}{
+		{
+			name: "Map",
+			v1:   map[string]int{"a": 1, "b": 2},
+			v2:   map[string]int{"a": 2, "c": 3},
+			want: `["a"]: 1 != 2
+["b"]: 2 != <missing>
+["c"]: <missing> != 3`,
+		},
+		{
+			name: "One Line",
+			v1:   v1,
+			v2:   v2,
+			want: `.Name: "x" != "y"
+.Inner.A[1]: 2 != 3
+.Inner.B: []int{} != []int{4}
+.Keys[[2]int{1, 2}]: "a" != "b"`,
+		},
+		{
+			name: "Env Options",
+			v1:   v1,
+			v2:   v2,
+			env: ops.WrapEnv(ops.NewEnv(),
+				ops.FmtOpt(reflect.TypeFor[string](), ops.FmtElide{}),
+				ops.FmtOptPath(".Inner.B", ops.FmtElide{}),
+				ops.FmtOptPath(".Inner[*]", ops.FmtFunc(func(ops.Env, reflect.Value) string {
+					return "never used"
+				})),
+			),
+			want: `.Name: string(...) != string(...)
+.Inner.A[1]: 2 != 3
+.Inner.B: []int(...) != []int(...)
+.Keys[[2]int{1, 2}]: string(...) != string(...)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ops.Diff(tt.env, tt.v1, tt.v2).String()
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffFormat(t *testing.T) {
+	d := ops.Diff(nil, []string{"a", "b"}, []string{"a", "c"})
+	env := ops.WrapEnv(ops.NewEnv(), ops.FmtOptPath("[*]", ops.FmtFunc(func(_ ops.Env, v reflect.Value) string {
+		return strings.ToUpper(v.String())
+	})))
+	if got, want := d.Format(env), "[1]: B != C"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := d.String(), `[1]: "b" != "c"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDiffCycle(t *testing.T) {
+	r1 := newRing(1, 2, 3)
+	r2 := newRing(1, 2, 4)
+	var gotPaths []string
+	for path := range ops.Diff(nil, r1, r2).Leaves() {
+		gotPaths = append(gotPaths, path)
+	}
+	want := []string{".Prev.Val", ".Next.Next.Val"}
+	if !slices.Equal(gotPaths, want) {
+		t.Errorf("got paths %q, want %q", gotPaths, want)
+	}
+}
@@ -1,6 +1,11 @@
 package ops
 
-import "reflect"
+import (
+	"maps"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
 
 type Tag any
 type Val any
@@ -47,10 +52,11 @@ func (e *mapEnv) Set(typ reflect.Type, tag Tag, val Val) {
 		*e = make(map[Tag]typeToVal)
 	}
 	ttvMap := func() mapTypeToVal {
-		if ttv, ok := (*e)[tag]; ok {
-			if ttvMap, ok := ttv.(mapTypeToVal); ok {
-				return ttvMap
-			}
+		switch ttv := (*e)[tag].(type) {
+		case mapTypeToVal:
+			return ttv
+		case *ruleTypeToVal:
+			return ttv.types
 		}
 		ttvMap := make(mapTypeToVal)
 		(*e)[tag] = ttvMap
@@ -82,6 +88,64 @@ func (e *mapEnv) Get(typ reflect.Type, tag Tag) (Val, bool) {
 	return nil, false
 }
 
+func (e *mapEnv) clone() *mapEnv {
+	out := make(mapEnv, len(*e))
+	for tag, ttv := range *e {
+		switch ttvCopy := ttv.(type) {
+		case mapTypeToVal:
+			ttv = maps.Clone(ttvCopy)
+		case *ruleTypeToVal:
+			ttv = ttvCopy.clone()
+		}
+		out[tag] = ttv
+	}
+	return &out
+}
+
+// NewSyncEnv returns an Env that is safe for concurrent use, meant to be
+// shared by many goroutines and updated only occasionally.  Get never blocks:
+// it reads an immutable snapshot, which Set and SetAll replace with an updated
+// copy.
+func NewSyncEnv() Env {
+	return &syncEnv{}
+}
+
+type syncEnv struct {
+	mu   sync.Mutex
+	data atomic.Pointer[mapEnv]
+}
+
+func (e *syncEnv) load() *mapEnv {
+	if data := e.data.Load(); data != nil {
+		return data
+	}
+	return &mapEnv{}
+}
+
+func (e *syncEnv) update(f func(*mapEnv)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	next := e.load().clone()
+	f(next)
+	e.data.Store(next)
+}
+
+func (e *syncEnv) Set(typ reflect.Type, tag Tag, val Val) {
+	e.update(func(data *mapEnv) {
+		data.Set(typ, tag, val)
+	})
+}
+
+func (e *syncEnv) SetAll(tag Tag, val Val) {
+	e.update(func(data *mapEnv) {
+		data.SetAll(tag, val)
+	})
+}
+
+func (e *syncEnv) Get(typ reflect.Type, tag Tag) (Val, bool) {
+	return e.load().Get(typ, tag)
+}
+
 type wrappedEnv struct {
 	parent Env
 	data *mapEnv
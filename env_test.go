@@ -2,7 +2,11 @@ package ops_test
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/krelinga/go-ops"
@@ -400,3 +404,307 @@ func TestSetAllOverridesSet(t *testing.T) {
 		t.Errorf("Expected all_value, got %v", val)
 	}
 }
+
+func TestSyncEnv(t *testing.T) {
+	type plugin struct {
+		ID   int
+		Name string
+	}
+	env := ops.NewSyncEnv()
+	ops.FmtOptCompact().Update(env)
+	stringType := reflect.TypeFor[string]()
+
+	const readers = 8
+	const writes = 100
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				wrapped := ops.WrapEnv(env, ops.EqOpt(stringType, ops.EqTrue{}))
+				p1 := plugin{ID: 1, Name: "a"}
+				p2 := plugin{ID: 1, Name: "b"}
+				if !ops.Equal(wrapped, p1, p2) {
+					t.Error("wrapped Eq was not used")
+					return
+				}
+				ops.Format(wrapped, p1)
+				ops.Hash(wrapped, []plugin{p1, p2})
+				if _, ok := env.Get(stringType, "missing"); ok {
+					t.Error("got a value for a tag that was never set")
+					return
+				}
+			}
+		}()
+	}
+	for i := range writes {
+		env.Set(reflect.TypeFor[int](), i%3, i)
+		env.SetAll("all", i)
+	}
+	close(done)
+	wg.Wait()
+
+	if val, ok := env.Get(reflect.TypeFor[int](), (writes-1)%3); !ok || val != writes-1 {
+		t.Errorf("got %v, %v, want %v, true", val, ok, writes-1)
+	}
+	if val, ok := env.Get(stringType, "all"); !ok || val != writes-1 {
+		t.Errorf("got %v, %v, want %v, true", val, ok, writes-1)
+	}
+}
+
+func TestSyncEnv_Errors(t *testing.T) {
+	env := ops.NewSyncEnv()
+	for _, f := range []func(){
+		func() { env.Set(nil, "tag", 1) },
+		func() { env.SetAll(nil, 1) },
+		func() { env.Get(reflect.TypeFor[int](), nil) },
+	} {
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if !errors.Is(err, ops.ErrNilType) && !errors.Is(err, ops.ErrNilTag) {
+					t.Errorf("got panic %v, want a nil type or tag error", err)
+				}
+			}()
+			f()
+		}()
+	}
+	if _, ok := env.Get(reflect.TypeFor[int](), "tag"); ok {
+		t.Error("failed Set changed the Env")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	intType := reflect.TypeFor[int]()
+	stringType := reflect.TypeFor[string]()
+
+	base := ops.NewEnv()
+	base.SetAll("all", "base")
+	base.Set(intType, "typed", "base int")
+	child := ops.WrapEnv(base, ops.OptFunc(func(env ops.Env) {
+		env.Set(intType, "all", "child int")
+		env.Set(stringType, "typed", "child string")
+	}))
+	frozen := ops.Freeze(child)
+
+	check := func(t *testing.T, env ops.Env, typ reflect.Type, tag ops.Tag, want ops.Val) {
+		t.Helper()
+		val, ok := env.Get(typ, tag)
+		if want == nil && ok {
+			t.Errorf("Get(%v, %v) = %v, want nothing", typ, tag, val)
+		} else if want != nil && (!ok || val != want) {
+			t.Errorf("Get(%v, %v) = %v, %v, want %v", typ, tag, val, ok, want)
+		}
+	}
+
+	t.Run("Same Values", func(t *testing.T) {
+		for _, env := range []ops.Env{child, frozen} {
+			check(t, env, intType, "all", "child int")
+			check(t, env, stringType, "all", "base")
+			check(t, env, intType, "typed", "base int")
+			check(t, env, stringType, "typed", "child string")
+			check(t, env, stringType, "missing", nil)
+		}
+	})
+
+	t.Run("Read Only", func(t *testing.T) {
+		for _, f := range []func(){
+			func() { frozen.Set(intType, "all", "changed") },
+			func() { frozen.SetAll("all", "changed") },
+		} {
+			func() {
+				defer func() {
+					if err, _ := recover().(error); !errors.Is(err, ops.ErrFrozen) {
+						t.Errorf("got panic %v, want %v", err, ops.ErrFrozen)
+					}
+				}()
+				f()
+			}()
+		}
+		check(t, frozen, stringType, "all", "base")
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		base.Set(stringType, "typed", "changed")
+		base.SetAll("new", "changed")
+		child.Set(intType, "all", "changed")
+		check(t, frozen, stringType, "typed", "child string")
+		check(t, frozen, intType, "all", "child int")
+		check(t, frozen, intType, "new", nil)
+	})
+
+	t.Run("Opaque", func(t *testing.T) {
+		opaque := struct{ ops.Env }{ops.NewEnv()}
+		frozen := ops.Freeze(ops.WrapEnv(opaque, ops.OptFunc(func(env ops.Env) {
+			env.Set(intType, "typed", "wrapped int")
+		})))
+		opaque.Set(stringType, "typed", "later string")
+		check(t, frozen, intType, "typed", "wrapped int")
+		check(t, frozen, stringType, "typed", "later string")
+	})
+
+	t.Run("Derived", func(t *testing.T) {
+		derived := ops.WrapEnv(frozen, ops.OptFunc(func(env ops.Env) {
+			env.Set(stringType, "all", "derived string")
+		}))
+		check(t, derived, stringType, "all", "derived string")
+		check(t, derived, reflect.TypeFor[bool](), "all", "base")
+		refrozen := ops.Freeze(derived)
+		check(t, refrozen, stringType, "all", "derived string")
+		check(t, refrozen, intType, "all", "child int")
+		check(t, refrozen, reflect.TypeFor[bool](), "all", "base")
+		check(t, frozen, stringType, "all", "base")
+		if ops.Freeze(refrozen) != refrozen {
+			t.Error("freezing a frozen Env made a copy")
+		}
+	})
+
+	t.Run("Many Derived", func(t *testing.T) {
+		const n = 1000
+		base := ops.NewEnv()
+		for i := range n {
+			base.SetAll(i, "base")
+			base.Set(intType, fmt.Sprint("typed", i), i)
+		}
+		frozen := ops.Freeze(base)
+		derived := make([]ops.Env, 0, n)
+		for i := range n {
+			derived = append(derived, ops.Freeze(ops.WrapEnv(frozen, ops.OptFunc(func(env ops.Env) {
+				env.SetAll(i, "derived")
+				env.Set(stringType, fmt.Sprint("typed", i), "derived")
+			}))))
+		}
+		for i, env := range derived {
+			for _, j := range []int{0, i, (i + 1) % n} {
+				want := "base"
+				if i == j {
+					want = "derived"
+				}
+				check(t, env, intType, j, want)
+				check(t, env, intType, fmt.Sprint("typed", j), j)
+			}
+			check(t, env, stringType, fmt.Sprint("typed", i), "derived")
+			check(t, env, stringType, fmt.Sprint("typed", (i+1)%n), nil)
+		}
+		check(t, frozen, intType, 0, "base")
+		if got := len(ops.EnvLayers(derived[0])[0].Entries); got != 2*n+1 {
+			t.Errorf("got %d entries, want %d", got, 2*n+1)
+		}
+	})
+
+	t.Run("Operations", func(t *testing.T) {
+		env := ops.Freeze(ops.WrapEnv(ops.NewSyncEnv(), ops.EqOpt(stringType, ops.EqTrue{})))
+		if !ops.Equal(env, []string{"a"}, []string{"b"}) {
+			t.Error("frozen Eq was not used")
+		}
+		if got := ops.Format(env, map[string]int{"a": 1}); got != "map[string]int{\n  \"a\": 1,\n}" {
+			t.Errorf("got %q", got)
+		}
+	})
+}
+
+func TestExplain(t *testing.T) {
+	intType := reflect.TypeFor[int]()
+	stringType := reflect.TypeFor[string]()
+
+	base := ops.NewEnv()
+	base.SetAll("fmt", "base fmt")
+	base.Set(intType, "eq", "base int eq")
+	env := ops.WrapEnv(base, ops.OptFunc(func(env ops.Env) {
+		env.Set(intType, "fmt", "int fmt")
+	}))
+
+	layers := ops.EnvLayers(env)
+	var names []string
+	for _, layer := range layers {
+		names = append(names, layer.Name)
+		for _, e := range layer.Entries {
+			names = append(names, "  "+e.String())
+		}
+	}
+	want := []string{
+		"WrapEnv",
+		`  Set(int, "fmt") = int fmt`,
+		"NewEnv",
+		`  Set(int, "eq") = base int eq`,
+		`  SetAll("fmt") = base fmt`,
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("got layers:\n%s\nwant:\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+
+	tests := []struct {
+		name string
+		env  ops.Env
+		typ  reflect.Type
+		tag  ops.Tag
+		want string
+	}{
+		{
+			name: "Set In Outer Layer",
+			env:  env,
+			typ:  intType,
+			tag:  "fmt",
+			want: `layer 0 (WrapEnv): Set(int, "fmt") = int fmt`,
+		},
+		{
+			name: "SetAll In Inner Layer",
+			env:  env,
+			typ:  stringType,
+			tag:  "fmt",
+			want: `layer 1 (NewEnv): SetAll("fmt") = base fmt`,
+		},
+		{
+			name: "Set In Inner Layer",
+			env:  env,
+			typ:  intType,
+			tag:  "eq",
+			want: `layer 1 (NewEnv): Set(int, "eq") = base int eq`,
+		},
+		{
+			name: "Missing",
+			env:  env,
+			typ:  stringType,
+			tag:  "eq",
+		},
+		{
+			name: "Frozen",
+			env:  ops.WrapEnv(ops.Freeze(env), ops.FmtOptCompact()),
+			typ:  stringType,
+			tag:  "fmt",
+			want: `layer 1 (Freeze): SetAll("fmt") = base fmt`,
+		},
+		{
+			name: "Struct Tag",
+			env:  ops.WrapEnv(ops.NewSyncEnv(), ops.FmtOpt(stringType, ops.FmtElide{})),
+			typ:  stringType,
+			tag:  ops.EnvLayers(ops.WrapEnv(nil, ops.FmtOpt(stringType, ops.FmtElide{})))[0].Entries[0].Tag,
+			want: `layer 0 (WrapEnv): Set(string, ops.fmtTag) = {}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, ok := ops.Explain(tt.env, tt.typ, tt.tag)
+			val, getOK := tt.env.Get(tt.typ, tt.tag)
+			if ok != getOK || (ok && x.Entry.Val != val) {
+				t.Errorf("Explain found %v, %v but Get found %v, %v", x.Entry.Val, ok, val, getOK)
+			}
+			if tt.want == "" {
+				if ok {
+					t.Errorf("got %s, want nothing", x)
+				}
+				return
+			}
+			if got := x.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
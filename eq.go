@@ -2,7 +2,12 @@ package ops
 
 import (
 	"fmt"
+	"hash/maphash"
+	"iter"
+	"math"
+	"math/cmplx"
 	"reflect"
+	"slices"
 )
 
 type Eq interface {
@@ -19,21 +24,22 @@ func EqualVals(env Env, v1, v2 reflect.Value) bool {
 	if typ != v2.Type() {
 		panic(ErrWrongType)
 	}
-	impl := func() Eq {
-		if env == nil {
-			return EqDefault{}
-		}
-		anyVal, ok := env.Get(typ, eqTag{})
-		if !ok {
-			return EqDefault{}
-		}
-		impl := anyVal.(Eq)
-		if impl == nil {
-			return EqDefault{}
-		}
-		return impl
-	}()
-	return impl.Eq(env, v1, v2)
+	return eqFor(env, typ).Eq(env, v1, v2)
+}
+
+func eqFor(env Env, typ reflect.Type) Eq {
+	if env == nil {
+		return EqDefault{}
+	}
+	anyVal, ok := env.Get(typ, eqTag{})
+	if !ok {
+		return EqDefault{}
+	}
+	impl := anyVal.(Eq)
+	if impl == nil {
+		return EqDefault{}
+	}
+	return impl
 }
 
 func Equal[T any](env Env, in1, in2 T) bool {
@@ -68,6 +74,9 @@ type EqDefault struct{}
 
 func (EqDefault) Eq(env Env, v1, v2 reflect.Value) bool {
 	t := v1.Type()
+	if useEqMethod(env, t) && v1.CanInterface() && v2.CanInterface() {
+		return EqMethod{}.Eq(env, v1, v2)
+	}
 	switch t.Kind() {
 	case reflect.Struct:
 		return EqStruct{}.Eq(env, v1, v2)
@@ -80,15 +89,20 @@ func (EqDefault) Eq(env Env, v1, v2 reflect.Value) bool {
 	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
 		// TODO: consider at least allowing both to be nil?
 		panic(fmt.Errorf("%w: cannot compare type %s", ErrInvalid, typeName(t)))
-	case reflect.Complex128, reflect.Complex64,
-		reflect.Float32, reflect.Float64,
-		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
-		reflect.String, reflect.Bool:
-		if !v1.CanInterface() || !v2.CanInterface() {
-			panic(ErrInvalid) // TODO: better error?  Or handle via unsafe?
-		}
-		return v1.Interface() == v2.Interface()
+	// These use the kind-specific accessors rather than Interface() so that
+	// values read from unexported fields can be compared.
+	case reflect.Complex128, reflect.Complex64:
+		return v1.Complex() == v2.Complex()
+	case reflect.Float32, reflect.Float64:
+		return v1.Float() == v2.Float()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v1.Int() == v2.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v1.Uint() == v2.Uint()
+	case reflect.String:
+		return v1.String() == v2.String()
+	case reflect.Bool:
+		return v1.Bool() == v2.Bool()
 	case reflect.Interface:
 		return EqInterface{}.Eq(env, v1, v2)
 	default:
@@ -96,6 +110,24 @@ func (EqDefault) Eq(env Env, v1, v2 reflect.Value) bool {
 	}
 }
 
+// EqMethod compares values of type T with their Equal(T) bool method, which
+// may have a pointer receiver.
+type EqMethod struct{}
+
+func (EqMethod) Eq(_ Env, v1, v2 reflect.Value) bool {
+	return callBinaryMethod("Equal", boolType, v1, v2).Bool()
+}
+
+// useEqMethod reports whether EqDefault compares values of type t with
+// EqMethod.
+func useEqMethod(env Env, t reflect.Type) bool {
+	if !flagSet(env, t, eqMethodsTag{}) {
+		return false
+	}
+	_, _, ok := binaryMethod(t, "Equal", boolType)
+	return ok
+}
+
 type EqOptFunc func(Env, reflect.Value, reflect.Value) bool
 
 func (f EqOptFunc) Eq(env Env, v1, v2 reflect.Value) bool {
@@ -129,6 +161,11 @@ func (cp EqPointer) Eq(env Env, v1, v2 reflect.Value) bool {
 	if v1.IsNil() || v2.IsNil() {
 		return v1.IsNil() && v2.IsNil()
 	}
+	env, s := withState(env)
+	if _, ok := s.eq.enter(v1, v2); !ok {
+		return true
+	}
+	defer s.eq.leave(v1, v2)
 	elem := cp.Elem
 	if elem == nil {
 		elem = EqDeep{}
@@ -161,36 +198,54 @@ func (ci EqInterface) Eq(env Env, v1, v2 reflect.Value) bool {
 
 type EqStruct struct {
 	Fields map[Field]Eq
+	// Unexported fields are skipped unless this is set or they have an entry
+	// in Fields or an "ops" struct tag.
+	IncludeUnexported bool
 }
 
-func (cs EqStruct) Eq(env Env, v1, v2 reflect.Value) bool {
-	t := v1.Type()
-	if t.Kind() != reflect.Struct {
-		panic(ErrWrongType)
-	}
+// fields yields each field of t that cs compares, along with the Eq to use
+// for it.
+func (cs EqStruct) fields(t reflect.Type) iter.Seq2[reflect.StructField, Eq] {
 	for f := range cs.Fields {
 		if f == nil {
 			panic(ErrNilField)
 		}
 	}
-	for fNum := range t.NumField() {
-		f := t.Field(fNum)
-		if !f.IsExported() {
-			// TODO: handle unexported fields?
-			continue
-		}
-		var key Field
-		if f.Anonymous {
-			key = EmbedField(f.Type)
-		} else {
-			key = NamedField(f.Name)
-		}
-		impl, ok := cs.Fields[key]
-		if !ok || impl == nil {
-			impl = EqDeep{}
+	tags := tagsFor(t)
+	return func(yield func(reflect.StructField, Eq) bool) {
+		for fNum := range t.NumField() {
+			f := t.Field(fNum)
+			impl, ok := cs.Fields[fieldKey(f)]
+			if !ok && tags != nil && tags[fNum].eq != nil {
+				impl, ok = tags[fNum].eq, true
+			}
+			if !f.IsExported() && !cs.IncludeUnexported && !ok {
+				continue
+			}
+			if impl == nil {
+				impl = EqDeep{}
+			}
+			if !yield(f, impl) {
+				return
+			}
 		}
-		val1 := v1.Field(fNum)
-		val2 := v2.Field(fNum)
+	}
+}
+
+func (cs EqStruct) Eq(env Env, v1, v2 reflect.Value) bool {
+	t := v1.Type()
+	if t.Kind() != reflect.Struct {
+		panic(ErrWrongType)
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	var fieldName string
+	defer annotateField(t, &fieldName)
+	for f, impl := range cs.fields(t) {
+		fieldName = f.Name
+		impl = pathChild(paths, fieldPath(f.Name), impl)
+		val1 := v1.Field(f.Index[0])
+		val2 := v2.Field(f.Index[0])
 		if !impl.Eq(env, val1, val2) {
 			return false
 		}
@@ -200,7 +255,8 @@ func (cs EqStruct) Eq(env Env, v1, v2 reflect.Value) bool {
 
 type EqSlice struct {
 	Elems Eq
-	// TODO: support unordered comparison?
+	// Compare as multisets, ignoring the order of elements.
+	Unordered bool
 }
 
 func (cs EqSlice) Eq(env Env, v1, v2 reflect.Value) bool {
@@ -213,23 +269,145 @@ func (cs EqSlice) Eq(env Env, v1, v2 reflect.Value) bool {
 	if v1.Len() != v2.Len() {
 		return false
 	}
-	if v1.IsNil() != v2.IsNil() {
+	if v1.Kind() == reflect.Slice && v1.IsNil() != v2.IsNil() {
 		return false
 	}
+	env, s := withState(env)
+	if _, ok := s.eq.enter(v1, v2); !ok {
+		return true
+	}
+	defer s.eq.leave(v1, v2)
 	elems := cs.Elems
 	if elems == nil {
 		elems = EqDeep{}
 	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	if cs.Unordered {
+		unmatched1, _ := matchUnordered(env, paths, elems, v1, v2)
+		return len(unmatched1) == 0
+	}
+	idx := -1
+	defer annotateIndex(v1.Type(), &idx)
 	for elemNum := range v1.Len() {
+		idx = elemNum
 		elem1 := v1.Index(elemNum)
 		elem2 := v2.Index(elemNum)
-		if !elems.Eq(env, elem1, elem2) {
+		if !pathChild(paths, indexPath(elemNum), elems).Eq(env, elem1, elem2) {
 			return false
 		}
 	}
 	return true
 }
 
+// matchUnordered pairs up equal elements of v1 and v2, returning the indices
+// of the elements that couldn't be paired.  Elements are first grouped by hash
+// and then matched within each group using augmenting paths, so that the
+// result is correct even if eq isn't transitive, as with EqFloat.  Matching
+// starts out greedy, so it takes O(n) calls to eq when the elements are all
+// equal.
+func matchUnordered(env Env, paths pathCursor, eq Eq, v1, v2 reflect.Value) ([]int, []int) {
+	hasher := hasherForEq(eq)
+	if paths.pending() {
+		hasher = HashNone{}
+	}
+	group := func(v reflect.Value) map[uint64][]int {
+		groups := make(map[uint64][]int)
+		for i := range v.Len() {
+			var h maphash.Hash
+			h.SetSeed(hashSeed)
+			hasher.Hash(env, &h, v.Index(i))
+			sum := h.Sum64()
+			groups[sum] = append(groups[sum], i)
+		}
+		return groups
+	}
+	groups1 := group(v1)
+	groups2 := group(v2)
+
+	idx := -1
+	defer annotateIndex(v1.Type(), &idx)
+	equal := func(i1, i2 int) bool {
+		idx = i1
+		impl := pathChild(paths, indexPath(i1), eq)
+		return impl.Eq(env, v1.Index(i1), v2.Index(i2))
+	}
+	var unmatched1, unmatched2 []int
+	for sum, left := range groups1 {
+		right := groups2[sum]
+		delete(groups2, sum)
+		left1, right1 := matchAugmenting(left, right, equal)
+		unmatched1 = append(unmatched1, left1...)
+		unmatched2 = append(unmatched2, right1...)
+	}
+	for _, right := range groups2 {
+		unmatched2 = append(unmatched2, right...)
+	}
+	slices.Sort(unmatched1)
+	slices.Sort(unmatched2)
+	return unmatched1, unmatched2
+}
+
+// matchAugmenting finds a maximum matching between left and right, starting
+// from a greedy one.  Pairs are compared as they're needed rather than all up
+// front, so equal elements are matched with O(n) calls to equal.
+func matchAugmenting(left, right []int, equal func(int, int) bool) ([]int, []int) {
+	// The position in left that each element of right is paired with, or -1.
+	matchR := make([]int, len(right))
+	for r := range matchR {
+		matchR[r] = -1
+	}
+	free := make([]int, len(right))
+	for r := range free {
+		free[r] = r
+	}
+	var pending []int
+	for l := range left {
+		i := slices.IndexFunc(free, func(r int) bool {
+			return equal(left[l], right[r])
+		})
+		if i < 0 {
+			pending = append(pending, l)
+			continue
+		}
+		matchR[free[i]] = l
+		free[i] = free[len(free)-1]
+		free = free[:len(free)-1]
+	}
+	var augment func(l int, seen []bool) bool
+	augment = func(l int, seen []bool) bool {
+		for r := range right {
+			if seen[r] || !equal(left[l], right[r]) {
+				continue
+			}
+			seen[r] = true
+			if matchR[r] < 0 || augment(matchR[r], seen) {
+				matchR[r] = l
+				return true
+			}
+		}
+		return false
+	}
+	var unmatched1, unmatched2 []int
+	for _, l := range pending {
+		if len(free) == 0 {
+			unmatched1 = append(unmatched1, left[l])
+			continue
+		}
+		if !augment(l, make([]bool, len(right))) {
+			unmatched1 = append(unmatched1, left[l])
+			continue
+		}
+		free = slices.DeleteFunc(free, func(r int) bool {
+			return matchR[r] >= 0
+		})
+	}
+	for _, r := range free {
+		unmatched2 = append(unmatched2, right[r])
+	}
+	return unmatched1, unmatched2
+}
+
 type EqMap struct {
 	Keys Eq
 	Vals Eq
@@ -245,6 +423,11 @@ func (cm EqMap) Eq(env Env, v1, v2 reflect.Value) bool {
 	if v1.IsNil() != v2.IsNil() {
 		return false
 	}
+	env, s := withState(env)
+	if _, ok := s.eq.enter(v1, v2); !ok {
+		return true
+	}
+	defer s.eq.leave(v1, v2)
 	keys := cm.Keys
 	if keys == nil {
 		keys = EqDeep{}
@@ -253,6 +436,22 @@ func (cm EqMap) Eq(env Env, v1, v2 reflect.Value) bool {
 	if vals == nil {
 		vals = EqDeep{}
 	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+
+	if isBuiltinEq(env, cm.Keys, v1.Type().Key()) {
+		var k reflect.Value
+		defer annotateKey(env, v1.Type(), &k)
+		i := v1.MapRange()
+		for i.Next() {
+			k = i.Key()
+			val2 := v2.MapIndex(k)
+			if !val2.IsValid() || !pathChild(paths, keyPath(k), vals).Eq(env, i.Value(), val2) {
+				return false
+			}
+		}
+		return true
+	}
 
 	type kv struct {
 		key reflect.Value
@@ -266,16 +465,23 @@ func (cm EqMap) Eq(env Env, v1, v2 reflect.Value) bool {
 		kvs1 = append(kvs1, kv{key: k, val: v})
 	}
 	used := make([]bool, len(kvs1))
+	var k2 reflect.Value
+	defer annotateKey(env, v1.Type(), &k2)
 	i = v2.MapRange()
 k2loop:
 	for i.Next() {
-		k2 := i.Key()
+		k2 = i.Key()
 		v2 := i.Value()
 		for idx, kv1 := range kvs1 {
 			if used[idx] {
 				continue
 			}
-			if !keys.Eq(env, kv1.key, k2) || !vals.Eq(env, kv1.val, v2) {
+			// Paths can't refer to anything inside keys.
+			paths.skip()
+			if !keys.Eq(env, kv1.key, k2) {
+				continue
+			}
+			if !pathChild(paths, keyPath(kv1.key), vals).Eq(env, kv1.val, v2) {
 				continue
 			}
 			used[idx] = true
@@ -287,6 +493,170 @@ k2loop:
 	return true
 }
 
+// EqFloat compares floats approximately: two values are equal if they are
+// within AbsTol of each other, within RelTol of the larger magnitude, or at
+// most ULPs representable values apart.  Values of other kinds are compared
+// with EqDefault, so EqFloat can be registered with EqOptAll.
+type EqFloat struct {
+	AbsTol   float64
+	RelTol   float64
+	ULPs     uint
+	NaNEqual bool
+}
+
+func isFloat(k reflect.Kind) bool {
+	return k == reflect.Float32 || k == reflect.Float64
+}
+
+func isComplex(k reflect.Kind) bool {
+	return k == reflect.Complex64 || k == reflect.Complex128
+}
+
+func orderedBits64(f float64) int64 {
+	b := int64(math.Float64bits(f))
+	if b < 0 {
+		b = math.MinInt64 - b
+	}
+	return b
+}
+
+func orderedBits32(f float32) int64 {
+	b := int32(math.Float32bits(f))
+	if b < 0 {
+		b = math.MinInt32 - b
+	}
+	return int64(b)
+}
+
+// ulps returns the number of representable values between a and b, which
+// must not be NaN.
+func ulps(a, b float64, is32 bool) uint64 {
+	var ia, ib int64
+	if is32 {
+		ia, ib = orderedBits32(float32(a)), orderedBits32(float32(b))
+	} else {
+		ia, ib = orderedBits64(a), orderedBits64(b)
+	}
+	if ia < ib {
+		ia, ib = ib, ia
+	}
+	return uint64(ia) - uint64(ib)
+}
+
+func (ef EqFloat) exact() bool {
+	return ef.AbsTol == 0 && ef.RelTol == 0 && ef.ULPs == 0
+}
+
+func (ef EqFloat) equal(a, b float64, is32 bool) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return ef.NaNEqual && math.IsNaN(a) && math.IsNaN(b)
+	}
+	if a == b {
+		return true
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return false
+	}
+	diff := math.Abs(a - b)
+	return diff <= ef.AbsTol ||
+		diff <= ef.RelTol*max(math.Abs(a), math.Abs(b)) ||
+		ulps(a, b, is32) <= uint64(ef.ULPs)
+}
+
+func (ef EqFloat) Eq(env Env, v1, v2 reflect.Value) bool {
+	if !isFloat(v1.Kind()) {
+		return EqDefault{}.Eq(env, v1, v2)
+	}
+	return ef.equal(v1.Float(), v2.Float(), v1.Kind() == reflect.Float32)
+}
+
+func (ef EqFloat) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if !isFloat(v1.Kind()) {
+		return EqDefault{}.Diff(env, v1, v2)
+	}
+	return diffWith(env, EqOptFunc(ef.Eq), v1, v2)
+}
+
+func hashFloatExact(h *maphash.Hash, f float64, nanEqual bool) {
+	if math.IsNaN(f) && nanEqual {
+		f = math.NaN()
+	}
+	hashFloat(h, f)
+}
+
+// Approximate equality isn't transitive, so the only consistent hash for an
+// EqFloat with tolerances is a constant.
+func (ef EqFloat) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	if !isFloat(v.Kind()) {
+		EqDefault{}.Hash(env, h, v)
+		return
+	}
+	if ef.exact() {
+		hashFloatExact(h, v.Float(), ef.NaNEqual)
+	}
+}
+
+// EqComplex compares complex numbers approximately: two values are equal if
+// the magnitude of their difference is within AbsTol, or within RelTol of the
+// larger magnitude, or if both their real and imaginary parts are at most ULPs
+// representable values apart.  Values of other kinds are compared with
+// EqDefault, so EqComplex can be registered with EqOptAll.
+type EqComplex struct {
+	AbsTol   float64
+	RelTol   float64
+	ULPs     uint
+	NaNEqual bool
+}
+
+func (ec EqComplex) exact() bool {
+	return ec.AbsTol == 0 && ec.RelTol == 0 && ec.ULPs == 0
+}
+
+func (ec EqComplex) Eq(env Env, v1, v2 reflect.Value) bool {
+	if !isComplex(v1.Kind()) {
+		return EqDefault{}.Eq(env, v1, v2)
+	}
+	a, b := v1.Complex(), v2.Complex()
+	if cmplx.IsNaN(a) || cmplx.IsNaN(b) {
+		return ec.NaNEqual && cmplx.IsNaN(a) && cmplx.IsNaN(b)
+	}
+	if a == b {
+		return true
+	}
+	if cmplx.IsInf(a) || cmplx.IsInf(b) {
+		return false
+	}
+	diff := cmplx.Abs(a - b)
+	if diff <= ec.AbsTol || diff <= ec.RelTol*max(cmplx.Abs(a), cmplx.Abs(b)) {
+		return true
+	}
+	is32 := v1.Kind() == reflect.Complex64
+	return ulps(real(a), real(b), is32) <= uint64(ec.ULPs) &&
+		ulps(imag(a), imag(b), is32) <= uint64(ec.ULPs)
+}
+
+func (ec EqComplex) Diff(env Env, v1, v2 reflect.Value) *DiffNode {
+	if !isComplex(v1.Kind()) {
+		return EqDefault{}.Diff(env, v1, v2)
+	}
+	return diffWith(env, EqOptFunc(ec.Eq), v1, v2)
+}
+
+func (ec EqComplex) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	if !isComplex(v.Kind()) {
+		EqDefault{}.Hash(env, h, v)
+		return
+	}
+	if ec.exact() {
+		c := v.Complex()
+		if cmplx.IsNaN(c) && ec.NaNEqual {
+			c = cmplx.NaN()
+		}
+		hashFloatExact(h, real(c), ec.NaNEqual)
+		hashFloatExact(h, imag(c), ec.NaNEqual)
+	}
+}
+
 func EqOpt(t reflect.Type, eq Eq) Opt {
 	return OptFunc(func(env Env) {
 		env.Set(t, eqTag{}, eq)
@@ -299,13 +669,79 @@ func EqOptAll(eq Eq) Opt {
 	})
 }
 
+// EqOptMethods makes EqDefault use EqMethod for any type with an Equal(T) bool
+// method.
+func EqOptMethods() Opt {
+	return flagOpt(eqMethodsTag{})
+}
+
+type eqBuiltin[T comparable] struct{}
+
+func (eqBuiltin[T]) Eq(_ Env, v1, v2 reflect.Value) bool {
+	if !v1.CanInterface() || !v2.CanInterface() {
+		panic(ErrInvalid) // TODO: better error?  Or handle via unsafe?
+	}
+	return v1.Interface().(T) == v2.Interface().(T)
+}
+
+func (eqBuiltin[T]) Hash(_ Env, h *maphash.Hash, v reflect.Value) {
+	if !v.CanInterface() {
+		panic(ErrInvalid)
+	}
+	maphash.WriteComparable(h, v.Interface().(T))
+}
+
+func (eqBuiltin[T]) builtinType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
 func EqOptBuiltin[T comparable]() Opt {
-	t := reflect.TypeFor[T]()
-	cmpFn := func(_ Env, v1, v2 reflect.Value) bool {
-		if !v1.CanInterface() || !v2.CanInterface() {
-			panic(ErrInvalid) // TODO: better error?  Or handle via unsafe?
+	return EqOpt(reflect.TypeFor[T](), eqBuiltin[T]{})
+}
+
+// isBuiltinEq reports whether eq, used to compare values of type t, behaves
+// exactly like Go's == operator.  When it does, maps keyed by t can be compared
+// with direct lookups rather than by searching for matching keys.
+func isBuiltinEq(env Env, eq Eq, t reflect.Type) bool {
+	if !t.Comparable() {
+		return false
+	}
+	switch eq := eq.(type) {
+	case nil, EqDeep:
+		return isBuiltinEq(env, eqFor(env, t), t)
+	case interface{ builtinType() reflect.Type }:
+		return eq.builtinType() == t
+	case EqDefault:
+		if useEqMethod(env, t) {
+			return false
 		}
-		return v1.Interface().(T) == v2.Interface().(T)
+		// This mirrors the cases in EqDefault.Eq.
+		switch t.Kind() {
+		case reflect.Complex128, reflect.Complex64,
+			reflect.Float32, reflect.Float64,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.String, reflect.Bool:
+			return true
+		case reflect.Array:
+			return isBuiltinEq(env, EqDeep{}, t.Elem())
+		case reflect.Struct:
+			tags := tagsFor(t)
+			for fNum := range t.NumField() {
+				f := t.Field(fNum)
+				// EqStruct skips unexported fields, but == doesn't.
+				if !f.IsExported() || !isBuiltinEq(env, EqDeep{}, f.Type) {
+					return false
+				}
+				if tags != nil && tags[fNum].eq != nil {
+					return false
+				}
+			}
+			return true
+		default:
+			return false
+		}
+	default:
+		return false
 	}
-	return EqOpt(t, EqOptFunc(cmpFn))
 }
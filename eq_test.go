@@ -0,0 +1,470 @@
+package ops_test
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+type listNode struct {
+	Val        int
+	Prev, Next *listNode
+}
+
+// newRing returns a doubly-linked ring containing vals.
+func newRing(vals ...int) *listNode {
+	nodes := make([]*listNode, len(vals))
+	for i, v := range vals {
+		nodes[i] = &listNode{Val: v}
+	}
+	for i, n := range nodes {
+		n.Next = nodes[(i+1)%len(nodes)]
+		n.Prev = nodes[(i+len(nodes)-1)%len(nodes)]
+	}
+	return nodes[0]
+}
+
+func TestEqual(t *testing.T) {
+	t.Run("Cycles", func(t *testing.T) {
+		selfSlice := []any{nil}
+		selfSlice[0] = selfSlice
+		otherSelfSlice := []any{nil}
+		otherSelfSlice[0] = otherSelfSlice
+		selfMap := map[string]any{}
+		selfMap["self"] = selfMap
+		otherSelfMap := map[string]any{}
+		otherSelfMap["self"] = otherSelfMap
+
+		tests := []struct {
+			name string
+			f    func() bool
+			want bool
+		}{
+			{
+				name: "Same Ring",
+				f: func() bool {
+					r := newRing(1, 2, 3)
+					return ops.Equal(nil, r, r)
+				},
+				want: true,
+			},
+			{
+				name: "Equivalent Rings",
+				f: func() bool {
+					return ops.Equal(nil, newRing(1, 2, 3), newRing(1, 2, 3))
+				},
+				want: true,
+			},
+			{
+				name: "Different Rings",
+				f: func() bool {
+					return ops.Equal(nil, newRing(1, 2, 3), newRing(1, 2, 4))
+				},
+				want: false,
+			},
+			{
+				name: "Self-Referential Slices",
+				f: func() bool {
+					return ops.Equal(nil, selfSlice, otherSelfSlice)
+				},
+				want: true,
+			},
+			{
+				name: "Self-Referential Maps",
+				f: func() bool {
+					return ops.Equal(nil, selfMap, otherSelfMap)
+				},
+				want: true,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := tt.f(); got != tt.want {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestEqSliceUnordered(t *testing.T) {
+	unordered := ops.EqSlice{Unordered: true}
+	withinOne := ops.EqSlice{
+		Unordered: true,
+		Elems: ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+			return max(v1.Int()-v2.Int(), v2.Int()-v1.Int()) <= 1
+		}),
+	}
+	tests := []struct {
+		name          string
+		eq            ops.Eq
+		v1, v2        []int
+		want          bool
+		wantDiffPaths []string
+	}{
+		{
+			name: "Permutation",
+			eq:   unordered,
+			v1:   []int{1, 2, 2, 3},
+			v2:   []int{3, 2, 1, 2},
+			want: true,
+		},
+		{
+			name:          "Different Multiplicity",
+			eq:            unordered,
+			v1:            []int{1, 2, 2},
+			v2:            []int{1, 1, 2},
+			want:          false,
+			wantDiffPaths: []string{"[2]", "[1]"},
+		},
+		{
+			name:          "Different Lengths",
+			eq:            unordered,
+			v1:            []int{1, 2},
+			v2:            []int{2, 1, 3},
+			want:          false,
+			wantDiffPaths: []string{"[2]"},
+		},
+		{
+			name: "Custom Eq Needs Augmenting Path",
+			eq:   withinOne,
+			v1:   []int{2, 1},
+			v2:   []int{1, 3},
+			want: true,
+		},
+		{
+			name:          "Custom Eq No Match",
+			eq:            withinOne,
+			v1:            []int{1, 1},
+			v2:            []int{1, 5},
+			want:          false,
+			wantDiffPaths: []string{"[1]", "[1]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[[]int](), tt.eq))
+			if got := ops.Equal(env, tt.v1, tt.v2); got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+			var gotPaths []string
+			for path := range ops.Diff(env, tt.v1, tt.v2).Leaves() {
+				gotPaths = append(gotPaths, path)
+			}
+			if !slices.Equal(gotPaths, tt.wantDiffPaths) {
+				t.Errorf("Diff() paths = %q, want %q", gotPaths, tt.wantDiffPaths)
+			}
+			if tt.want && ops.Hash(env, tt.v1) != ops.Hash(env, tt.v2) {
+				t.Error("equal values have different hashes")
+			}
+		})
+	}
+
+	t.Run("Non-Transitive Eq Inside Hashed Elements", func(t *testing.T) {
+		type elem struct {
+			A int
+			F float64
+		}
+		env := ops.WrapEnv(ops.NewEnv(),
+			ops.EqOpt(reflect.TypeFor[float64](), ops.EqFloat{AbsTol: 1}),
+			ops.EqOpt(reflect.TypeFor[[]elem](), unordered))
+		v1 := []elem{{1, 1}, {1, 0}}
+		v2 := []elem{{1, 0}, {1, 2}}
+		if !ops.Equal(env, v1, v2) {
+			t.Errorf("expected %v and %v to be equal", v1, v2)
+		}
+	})
+}
+
+func TestEqSliceUnorderedDuplicates(t *testing.T) {
+	const n = 20000
+	zeros := make([]int, n)
+	oneOff := slices.Clone(zeros)
+	oneOff[n/2] = 1
+	tests := []struct {
+		name   string
+		eq     ops.Eq
+		v1, v2 []int
+		want   bool
+	}{
+		{
+			name: "Hashed Equal",
+			eq:   ops.EqSlice{Unordered: true},
+			v1:   zeros,
+			v2:   zeros,
+			want: true,
+		},
+		{
+			name: "Hashed Different",
+			eq:   ops.EqSlice{Unordered: true},
+			v1:   zeros,
+			v2:   oneOff,
+			want: false,
+		},
+		{
+			name: "Unhashed Equal",
+			eq: ops.EqSlice{
+				Unordered: true,
+				Elems: ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+					return v1.Int() == v2.Int()
+				}),
+			},
+			v1:   zeros,
+			v2:   zeros,
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[[]int](), tt.eq))
+			if got := ops.Equal(env, tt.v1, tt.v2); got != tt.want {
+				t.Errorf("Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEqMap(t *testing.T) {
+	type key struct {
+		Name   string
+		hidden int
+	}
+	caseless := ops.EqOpt(reflect.TypeFor[string](), ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+		return strings.EqualFold(v1.String(), v2.String())
+	}))
+	tests := []struct {
+		name string
+		opt  ops.Opt
+		f    func(ops.Env) bool
+		want bool
+	}{
+		{
+			name: "Builtin Keys",
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[string]int{"a": 1, "b": 2}, map[string]int{"b": 2, "a": 1})
+			},
+			want: true,
+		},
+		{
+			name: "Builtin Keys Missing Key",
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[string]int{"a": 1, "b": 2}, map[string]int{"a": 1, "c": 2})
+			},
+			want: false,
+		},
+		{
+			name: "EqOptBuiltin Keys",
+			opt:  ops.EqOptBuiltin[string](),
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[string]int{"a": 1}, map[string]int{"a": 1})
+			},
+			want: true,
+		},
+		{
+			name: "Custom Key Eq",
+			opt:  caseless,
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[string]int{"a": 1, "B": 2}, map[string]int{"A": 1, "b": 2})
+			},
+			want: true,
+		},
+		{
+			name: "Custom Key Eq On Struct Field",
+			opt:  caseless,
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[key]int{{Name: "a"}: 1}, map[key]int{{Name: "A"}: 1})
+			},
+			want: true,
+		},
+		{
+			name: "Unexported Key Fields Are Ignored",
+			f: func(env ops.Env) bool {
+				return ops.Equal(env, map[key]int{{Name: "a", hidden: 1}: 1}, map[key]int{{Name: "a", hidden: 2}: 1})
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ops.NewEnv()
+			if tt.opt != nil {
+				env = ops.WrapEnv(env, tt.opt)
+			}
+			if got := tt.f(env); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkEqMap(b *testing.B) {
+	customKeys := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[string](), ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+		return v1.String() == v2.String()
+	})))
+	envs := []struct {
+		name string
+		env  ops.Env
+	}{
+		{name: "DefaultKeys", env: nil},
+		{name: "CustomKeyEq", env: customKeys},
+	}
+	for _, size := range []int{100, 1000, 5000} {
+		m1 := make(map[string]int, size)
+		m2 := make(map[string]int, size)
+		for i := range size {
+			m1[fmt.Sprint(i)] = i
+			m2[fmt.Sprint(i)] = i
+		}
+		for _, e := range envs {
+			b.Run(fmt.Sprintf("%s/%d", e.name, size), func(b *testing.B) {
+				for b.Loop() {
+					if !ops.Equal(e.env, m1, m2) {
+						b.Fatal("maps are not equal")
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEqFloat(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name   string
+		eq     ops.Eq
+		v1, v2 any
+		want   bool
+	}{
+		{name: "Exact", eq: ops.EqFloat{}, v1: 1.0, v2: 1.0, want: true},
+		{name: "Exact Mismatch", eq: ops.EqFloat{}, v1: 1.0, v2: 1.0000001, want: false},
+		{name: "AbsTol", eq: ops.EqFloat{AbsTol: 1e-6}, v1: 1.0, v2: 1.0000001, want: true},
+		{name: "AbsTol Exceeded", eq: ops.EqFloat{AbsTol: 1e-6}, v1: 1.0, v2: 1.00001, want: false},
+		{name: "RelTol", eq: ops.EqFloat{RelTol: 1e-3}, v1: 1000.0, v2: 1000.5, want: true},
+		{name: "RelTol Exceeded", eq: ops.EqFloat{RelTol: 1e-3}, v1: 1.0, v2: 1.5, want: false},
+		{name: "ULPs", eq: ops.EqFloat{ULPs: 2}, v1: 1.0, v2: math.Nextafter(math.Nextafter(1, 2), 2), want: true},
+		{name: "ULPs Exceeded", eq: ops.EqFloat{ULPs: 1}, v1: 1.0, v2: math.Nextafter(math.Nextafter(1, 2), 2), want: false},
+		{name: "ULPs Across Zero", eq: ops.EqFloat{ULPs: 2}, v1: math.SmallestNonzeroFloat64, v2: -math.SmallestNonzeroFloat64, want: true},
+		{name: "ULPs Float32", eq: ops.EqFloat{ULPs: 1}, v1: float32(1), v2: math.Nextafter32(1, 2), want: true},
+		{name: "NaN", eq: ops.EqFloat{AbsTol: 1}, v1: nan, v2: nan, want: false},
+		{name: "NaNEqual", eq: ops.EqFloat{NaNEqual: true}, v1: nan, v2: nan, want: true},
+		{name: "Infinity", eq: ops.EqFloat{RelTol: 1}, v1: math.Inf(1), v2: math.MaxFloat64, want: false},
+		{name: "Non-Float Kind", eq: ops.EqFloat{AbsTol: 1}, v1: 1, v2: 2, want: false},
+		{name: "Complex AbsTol", eq: ops.EqComplex{AbsTol: 1e-6}, v1: 1 + 1i, v2: 1 + 1.0000001i, want: true},
+		{name: "Complex AbsTol Exceeded", eq: ops.EqComplex{AbsTol: 1e-6}, v1: 1 + 1i, v2: 1 + 1.1i, want: false},
+		{name: "Complex NaNEqual", eq: ops.EqComplex{NaNEqual: true}, v1: complex(nan, 0), v2: complex(0, nan), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v1 := reflect.ValueOf(tt.v1)
+			v2 := reflect.ValueOf(tt.v2)
+			env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(v1.Type(), tt.eq))
+			if got := ops.EqualVals(env, v1, v2); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if tt.want && ops.HashVals(env, v1) != ops.HashVals(env, v2) {
+				t.Error("equal values have different hashes")
+			}
+		})
+	}
+
+	t.Run("Struct Field", func(t *testing.T) {
+		type Sample struct {
+			Name  string
+			Value float64
+		}
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[Sample](), ops.EqStruct{
+			Fields: map[ops.Field]ops.Eq{
+				ops.NamedField("Value"): ops.EqFloat{AbsTol: 0.01},
+			},
+		}))
+		if !ops.Equal(env, Sample{Name: "a", Value: 1}, Sample{Name: "a", Value: 1.001}) {
+			t.Error("values within tolerance are not equal")
+		}
+	})
+
+	t.Run("EqOptAll", func(t *testing.T) {
+		type Sample struct {
+			Name   string
+			Values []float64
+		}
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOptAll(ops.EqFloat{AbsTol: 0.01}))
+		s1 := Sample{Name: "a", Values: []float64{1, 2}}
+		s2 := Sample{Name: "a", Values: []float64{1.001, 2}}
+		if !ops.Equal(env, s1, s2) {
+			t.Error("values within tolerance are not equal")
+		}
+		s2.Name = "b"
+		if got := ops.Diff(env, s1, s2); got == nil || got.Children[0].Step != ".Name" {
+			t.Errorf("got diff %v, want a difference at .Name", got)
+		}
+	})
+}
+
+func TestEqStructUnexported(t *testing.T) {
+	type counter struct {
+		Name  string
+		count int
+		tags  map[string]bool
+	}
+	c1 := counter{Name: "a", count: 1, tags: map[string]bool{"x": true}}
+	c2 := counter{Name: "a", count: 2, tags: map[string]bool{"x": true}}
+	tests := []struct {
+		name     string
+		eq       ops.Eq
+		want     bool
+		wantDiff string
+	}{
+		{
+			name: "Skipped By Default",
+			eq:   ops.EqStruct{},
+			want: true,
+		},
+		{
+			name:     "IncludeUnexported",
+			eq:       ops.EqStruct{IncludeUnexported: true},
+			want:     false,
+			wantDiff: ".count: 1 != 2",
+		},
+		{
+			name: "Field Override",
+			eq: ops.EqStruct{
+				Fields: map[ops.Field]ops.Eq{
+					ops.NamedField("count"): ops.EqDeep{},
+				},
+			},
+			want:     false,
+			wantDiff: ".count: 1 != 2",
+		},
+		{
+			name: "Field Override Ignores",
+			eq: ops.EqStruct{
+				IncludeUnexported: true,
+				Fields: map[ops.Field]ops.Eq{
+					ops.NamedField("count"): ops.EqTrue{},
+				},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[counter](), tt.eq))
+			if got := ops.Equal(env, c1, c2); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			if got := ops.Diff(env, c1, c2); tt.wantDiff == "" && got != nil {
+				t.Errorf("got diff %v, want none", got)
+			} else if tt.wantDiff != "" && (got == nil || got.String() != tt.wantDiff) {
+				t.Errorf("got diff %v, want %q", got, tt.wantDiff)
+			}
+			if tt.want && ops.Hash(env, c1) != ops.Hash(env, c2) {
+				t.Error("equal values have different hashes")
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package ops
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
 
 var (
 	ErrNilType   = errors.New("type cannot be nil")
@@ -9,21 +13,44 @@ var (
 	ErrWrongType = errors.New("value has wrong type")
 	ErrInternal  = errors.New("internal error")
 	ErrInvalid   = errors.New("invalid value")
+	ErrBadTag    = errors.New("invalid struct tag")
+	ErrFrozen    = errors.New("env is frozen")
+	ErrBadPath   = errors.New("invalid path")
 )
 
+// PathError records where inside a value of type Type an error happened.
+type PathError struct {
+	Path string
+	Type reflect.Type
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s%s: %v", typeName(e.Type), e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+func isOpsError(err error) bool {
+	return errors.Is(err, ErrNilType) ||
+		errors.Is(err, ErrNilTag) ||
+		errors.Is(err, ErrNilField) ||
+		errors.Is(err, ErrWrongType) ||
+		errors.Is(err, ErrInternal) ||
+		errors.Is(err, ErrInvalid) ||
+		errors.Is(err, ErrBadTag) ||
+		errors.Is(err, ErrFrozen) ||
+		errors.Is(err, ErrBadPath)
+}
+
 func try(f func()) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			if recErr, ok := r.(error); ok {
-				if errors.Is(recErr, ErrNilType) ||
-					errors.Is(recErr, ErrNilTag) ||
-					errors.Is(recErr, ErrNilField) ||
-					errors.Is(recErr, ErrWrongType) ||
-					errors.Is(recErr, ErrInternal) ||
-					errors.Is(recErr, ErrInvalid) {
-					err = recErr
-					return
-				}
+			if recErr, ok := r.(error); ok && isOpsError(recErr) {
+				err = recErr
+				return
 			}
 			panic(r)
 		}
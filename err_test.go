@@ -0,0 +1,86 @@
+package ops_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+func TestPathError(t *testing.T) {
+	type Config struct {
+		Handlers []any
+		Limits   map[string]any
+	}
+	type Service struct {
+		Name   string
+		Config Config
+	}
+	newService := func() Service {
+		return Service{
+			Name: "svc",
+			Config: Config{
+				Handlers: []any{1, 2, func() {}},
+				Limits:   map[string]any{"max": true},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		f        func() error
+		wantPath string
+		wantErr  error
+	}{
+		{
+			name: "Equal",
+			f: func() error {
+				_, err := ops.TryEqual(nil, newService(), newService())
+				return err
+			},
+			wantPath: ".Config.Handlers[2]",
+			wantErr:  ops.ErrInvalid,
+		},
+		{
+			name: "Order",
+			f: func() error {
+				s1 := newService()
+				s1.Config.Handlers = nil
+				s2 := newService()
+				s2.Config.Handlers = nil
+				_, err := ops.TryOrder(nil, s1, s2)
+				return err
+			},
+			wantPath: `.Config.Limits`,
+			wantErr:  ops.ErrWrongType,
+		},
+		{
+			name: "Format",
+			f: func() error {
+				env := ops.WrapEnv(ops.NewEnv(), ops.FmtOpt(reflect.TypeFor[bool](), ops.FmtStringer{}))
+				_, err := ops.TryFormat(env, newService())
+				return err
+			},
+			wantPath: `.Config.Limits["max"]`,
+			wantErr:  ops.ErrWrongType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.f()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			var pathErr *ops.PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("got error %v, want a *ops.PathError", err)
+			}
+			if pathErr.Path != tt.wantPath {
+				t.Errorf("got path %q, want %q", pathErr.Path, tt.wantPath)
+			}
+			if pathErr.Type != reflect.TypeFor[Service]() {
+				t.Errorf("got type %v, want %v", pathErr.Type, reflect.TypeFor[Service]())
+			}
+		})
+	}
+}
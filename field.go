@@ -27,3 +27,10 @@ type embedField struct {
 }
 
 func (ef embedField) fieldIsAClosedType() {}
+
+func fieldKey(f reflect.StructField) Field {
+	if f.Anonymous {
+		return EmbedField(f.Type)
+	}
+	return NamedField(f.Name)
+}
@@ -1,13 +1,20 @@
 package ops
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"reflect"
+	"slices"
 	"strings"
 )
 
 type fmtTag struct{}
 
+// Options that apply to a whole value, rather than to values of a particular
+// type, are looked up under fmtType.
+var fmtType = reflect.TypeFor[Fmt]()
+
 type Fmt interface {
 	Fmt(Env, reflect.Value) string
 }
@@ -24,25 +31,79 @@ func (FmtElide) Fmt(_ Env, v reflect.Value) string {
 	return fmt.Sprintf("%s(...)", typeName(v.Type()))
 }
 
+// FmtWriter is implemented by Fmts that can write a value directly to an
+// io.Writer.  FormatTo streams their output, including within composite values
+// at the default width.  With FmtOptWidth, their output within a composite
+// value is collected before it's laid out.
+type FmtWriter interface {
+	Fmt
+	FmtTo(io.Writer, Env, reflect.Value) error
+}
+
 func FormatVal(env Env, v reflect.Value) string {
+	var sb strings.Builder
+	if err := formatTo(&sb, env, v); err != nil {
+		// Only a FmtWriter can fail to write to a strings.Builder.
+		panic(err)
+	}
+	return sb.String()
+}
+
+func formatTo(w io.Writer, env Env, v reflect.Value) error {
 	if !v.IsValid() {
-		return "<invalid>"
+		_, err := io.WriteString(w, "<invalid>")
+		return err
 	}
-	impl := func() Fmt {
-		if env == nil {
-			return fmtDefault{}
+	switch impl := fmtFor(env, v.Type()).(type) {
+	case DocFmt:
+		return renderDocTo(w, env, impl.FmtDoc(env, v))
+	case FmtWriter:
+		lw := newFmtLimitWriter(w, env)
+		if err := impl.FmtTo(lw, env, v); err != nil {
+			return err
 		}
-		anyVal, ok := env.Get(v.Type(), fmtTag{})
-		if !ok {
-			return fmtDefault{}
-		}
-		impl := anyVal.(Fmt)
-		if impl == nil {
-			return fmtDefault{}
-		}
-		return impl
-	}()
-	return impl.Fmt(env, v)
+		return lw.finish()
+	default:
+		return renderDocTo(w, env, fmtLeaf(env, impl.Fmt(env, v)))
+	}
+}
+
+// FormatValTo writes v to w as it's formatted, rather than building up the
+// whole string first.  With FmtOptWidth, the layout of a composite value
+// depends on everything in it, so it's formatted in full before it's written.
+// It returns any error from w, or any error that TryFormatVals would have
+// returned.
+func FormatValTo(w io.Writer, env Env, v reflect.Value) error {
+	bw := bufio.NewWriter(w)
+	var writeErr error
+	if err := try(func() {
+		writeErr = formatTo(bw, env, v)
+	}); err != nil {
+		return err
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	return bw.Flush()
+}
+
+func FormatTo[T any](w io.Writer, env Env, in T) error {
+	return FormatValTo(w, env, ValueFor(in))
+}
+
+func fmtFor(env Env, typ reflect.Type) Fmt {
+	if env == nil {
+		return fmtDefault{}
+	}
+	anyVal, ok := env.Get(typ, fmtTag{})
+	if !ok {
+		return fmtDefault{}
+	}
+	impl := anyVal.(Fmt)
+	if impl == nil {
+		return fmtDefault{}
+	}
+	return impl
 }
 
 func Format[T any](env Env, in T) string {
@@ -78,6 +139,13 @@ func (FmtDeep) Fmt(env Env, v reflect.Value) string {
 	return FormatVal(env, v)
 }
 
+func (FmtDeep) FmtDoc(env Env, v reflect.Value) Doc {
+	if !v.IsValid() {
+		return DocText("<invalid>")
+	}
+	return fmtDoc(env, fmtFor(env, v.Type()), v)
+}
+
 type fmtDefault struct{}
 
 func literalStringCan[T any](v reflect.Value, can func(reflect.Value) bool, f func(reflect.Value) T) string {
@@ -115,32 +183,44 @@ func literalString[T any](v reflect.Value, f func(reflect.Value) T) string {
 	}
 }
 
-func (fmtDefault) Fmt(env Env, v reflect.Value) string {
+func (d fmtDefault) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, d.FmtDoc(env, v))
+}
+
+func (fmtDefault) FmtDoc(env Env, v reflect.Value) Doc {
+	if flagSet(env, v.Type(), fmtMethodsTag{}) && v.CanInterface() {
+		if _, ok := implementsIface(v.Type(), goStringerType); ok {
+			return fmtLeaf(env, FmtGoStringer{}.Fmt(env, v))
+		}
+		if _, ok := implementsIface(v.Type(), formatterType); ok {
+			return fmtLeaf(env, FmtFormatter{}.Fmt(env, v))
+		}
+	}
 	switch v.Kind() {
 	case reflect.Struct:
-		return FmtStruct{}.Fmt(env, v)
+		return FmtStruct{}.FmtDoc(env, v)
 	case reflect.Map:
-		return FmtMap{}.Fmt(env, v)
+		return FmtMap{}.FmtDoc(env, v)
 	case reflect.Slice, reflect.Array:
-		return FmtSlice{}.Fmt(env, v)
+		return FmtSlice{}.FmtDoc(env, v)
 	case reflect.Pointer:
-		return FmtPointer{}.Fmt(env, v)
+		return FmtPointer{}.FmtDoc(env, v)
 	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
-		return FmtElide{}.Fmt(env, v)
+		return fmtLeaf(env, FmtElide{}.Fmt(env, v))
 	case reflect.Complex128, reflect.Complex64:
-		return literalStringCan(v, reflect.Value.CanComplex, reflect.Value.Complex)
+		return fmtLeaf(env, literalStringCan(v, reflect.Value.CanComplex, reflect.Value.Complex))
 	case reflect.Float32, reflect.Float64:
-		return literalStringCan(v, reflect.Value.CanFloat, reflect.Value.Float)
+		return fmtLeaf(env, literalStringCan(v, reflect.Value.CanFloat, reflect.Value.Float))
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return literalStringCan(v, reflect.Value.CanInt, reflect.Value.Int)
+		return fmtLeaf(env, literalStringCan(v, reflect.Value.CanInt, reflect.Value.Int))
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		return literalStringCan(v, reflect.Value.CanUint, reflect.Value.Uint)
+		return fmtLeaf(env, literalStringCan(v, reflect.Value.CanUint, reflect.Value.Uint))
 	case reflect.Bool:
-		return literalString(v, reflect.Value.Bool)
+		return fmtLeaf(env, literalString(v, reflect.Value.Bool))
 	case reflect.String:
-		return literalString(v, reflect.Value.String)
+		return fmtText(env, fmtStringLimited(env, v))
 	case reflect.Interface:
-		return FmtInterface{}.Fmt(env, v)
+		return FmtInterface{}.FmtDoc(env, v)
 	default:
 		panic(fmt.Errorf("%w: unsupported kind %v for value %v", ErrInternal, v.Kind(), v))
 	}
@@ -148,10 +228,18 @@ func (fmtDefault) Fmt(env Env, v reflect.Value) string {
 
 type FmtStruct struct {
 	Fields map[Field]Fmt
-	// TODO: possibly add an option to include unexported fields?
+	// Unexported fields are elided as "..." unless this is set or they have
+	// an entry in Fields or an "ops" struct tag.
+	IncludeUnexported bool
+	// Overrides the Env's FmtLimits for this value and everything in it.
+	Limits FmtLimits
 }
 
 func (sf FmtStruct) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, sf.FmtDoc(env, v))
+}
+
+func (sf FmtStruct) FmtDoc(env Env, v reflect.Value) Doc {
 	t := v.Type()
 	if t.Kind() != reflect.Struct {
 		panic(ErrWrongType)
@@ -162,46 +250,139 @@ func (sf FmtStruct) Fmt(env Env, v reflect.Value) string {
 		}
 	}
 	// TODO: check for entries in sf.Fields that don't exist in t?
-	var filtered bool
-	fieldStrings := make([]string, 0, t.NumField())
-	for fNum := range t.NumField() {
-		f := t.Field(fNum)
-		if !f.IsExported() {
-			filtered = true
-			continue
-		}
-		var key Field
-		var name string
-		if f.Anonymous {
-			key = EmbedField(f.Type)
-			name = typeName(f.Type)
-		} else {
-			key = NamedField(f.Name)
-			name = f.Name
+	tags := tagsFor(t)
+	return fmtStream(env, func(emit func(Doc)) {
+		env, s := withState(env)
+		frame, ok, leave := enterFmtLimits(env, s, sf.Limits)
+		defer leave()
+		if !ok {
+			emit(fmtLeaf(env, typeName(t)+"{...}"))
+			return
 		}
-		impl, ok := sf.Fields[key]
-		if !ok || impl == nil {
-			impl = FmtDeep{}
+		countFmtBytes(env, len(typeName(t))+len("{}"))
+		env, paths := enterPaths(env, fmtPathTag{})
+		defer paths.leave()
+		var fieldName string
+		defer annotateField(t, &fieldName)
+		emit(fmtComposite(env, t, t.NumField() == 0, func(add func(Doc)) string {
+			var filtered bool
+			var shown, more int
+			for fNum := range t.NumField() {
+				f := t.Field(fNum)
+				impl, ok := sf.Fields[fieldKey(f)]
+				if !ok && tags != nil && tags[fNum].fmt != nil {
+					impl, ok = tags[fNum].fmt, true
+				}
+				if !f.IsExported() && !sf.IncludeUnexported && !ok {
+					filtered = true
+					continue
+				}
+				if more > 0 || frame.exhausted() {
+					more++
+					continue
+				}
+				fieldName = f.Name
+				name := f.Name
+				if f.Anonymous {
+					name = typeName(f.Type)
+				}
+				if impl == nil {
+					impl = FmtDeep{}
+				}
+				impl = pathChild(paths, fieldPath(f.Name), impl)
+				countFmtBytes(env, fmtSepBytes(shown)+len(name)+len(": "))
+				val := v.Field(fNum)
+				add(DocConcat(DocText(name+": "), fmtDoc(env, impl, val)))
+				shown++
+			}
+			if more > 0 {
+				return moreString(more)
+			}
+			if filtered {
+				return "..."
+			}
+			return ""
+		}))
+	})
+}
+
+type fmtCompactTag struct{}
+
+// FmtOptCompact makes FmtStruct, FmtMap, and FmtSlice render values on a
+// single line, like Foo{A: 1, B: "x"}, regardless of FmtOptWidth.
+func FmtOptCompact() Opt {
+	return flagOpt(fmtCompactTag{})
+}
+
+// fmtComposite lays out a value of type t, which has no parts if empty is
+// set.  parts adds the Doc for each of the value's parts in turn, and returns
+// a tail that describes any parts that were left out.
+func fmtComposite(env Env, t reflect.Type, empty bool, parts func(add func(Doc)) string) Doc {
+	compact := flagSet(env, t, fmtCompactTag{})
+	if empty {
+		if !compact && fmtWidth(env) == 0 {
+			// This is how empty values were always formatted before widths
+			// were supported.
+			return DocText(typeName(t) + "{\n\n}")
 		}
-		val := v.Field(fNum)
-		fieldStrings = append(fieldStrings, fmt.Sprintf("%s: %s,", name, impl.Fmt(env, val)))
+		return DocText(typeName(t) + "{}")
 	}
-	if filtered {
-		fieldStrings = append(fieldStrings, "...")
-	}
-	for i := range fieldStrings {
-		fieldStrings[i] = indent(fieldStrings[i])
+	inner := fmtStream(env, func(emit func(Doc)) {
+		n := 0
+		tail := parts(func(part Doc) {
+			if n > 0 {
+				emit(DocText(","))
+				emit(DocLine())
+			}
+			emit(part)
+			n++
+		})
+		if tail == "" {
+			emit(DocIfBreak(DocText(","), nil))
+			return
+		}
+		countFmtBytes(env, fmtSepBytes(n)+len(tail))
+		if n > 0 {
+			emit(DocText(","))
+			emit(DocLine())
+		}
+		emit(DocText(tail))
+	})
+	return docGroup{
+		doc: DocConcat(
+			DocText(typeName(t)+"{"),
+			DocNest(DocConcat(DocSoftLine(), inner)),
+			DocSoftLine(),
+			DocText("}"),
+		),
+		flat: compact,
 	}
-	return fmt.Sprintf("%s{\n%s\n}", typeName(t), strings.Join(fieldStrings, "\n"))
 }
 
+type FmtMapOrder int
+
+const (
+	// Orders keys with the Env's Ord for the key type, falling back to
+	// FmtMapOrderString if the key type can't be ordered.
+	FmtMapOrderAuto FmtMapOrder = iota
+	FmtMapOrderOrd
+	FmtMapOrderString
+)
+
 type FmtMap struct {
-	Keys Fmt
-	Vals Fmt
-	// TODO: possibly add an option to limit number of entries?  or to elide certain keys?
+	Keys  Fmt
+	Vals  Fmt
+	Order FmtMapOrder
+	// Overrides the Env's FmtLimits for this value and everything in it.
+	Limits FmtLimits
+	// TODO: possibly add an option to elide certain keys?
 }
 
 func (mf FmtMap) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, mf.FmtDoc(env, v))
+}
+
+func (mf FmtMap) FmtDoc(env Env, v reflect.Value) Doc {
 	t := v.Type()
 	if t.Kind() != reflect.Map {
 		panic(ErrWrongType)
@@ -214,25 +395,113 @@ func (mf FmtMap) Fmt(env Env, v reflect.Value) string {
 	if vals == nil {
 		vals = FmtDeep{}
 	}
-	entryStrings := make([]string, 0, v.Len())
-	i := v.MapRange()
-	for i.Next() {
-		k := i.Key()
-		val := i.Value()
-		entryStrings = append(entryStrings, fmt.Sprintf("%s: %s,", keys.Fmt(env, k), vals.Fmt(env, val)))
-	}
-	for i := range entryStrings {
-		entryStrings[i] = indent(entryStrings[i])
-	}
-	return fmt.Sprintf("%s{\n%s\n}", typeName(t), strings.Join(entryStrings, "\n"))
+	return fmtStream(env, func(emit func(Doc)) {
+		env, s := withState(env)
+		if pos, ok := s.fmt.enter(v, v); !ok {
+			emit(fmtLeaf(env, cycleString(pos)))
+			return
+		}
+		defer s.fmt.leave(v, v)
+		frame, ok, leave := enterFmtLimits(env, s, mf.Limits)
+		defer leave()
+		if !ok {
+			emit(fmtLeaf(env, typeName(t)+"{...}"))
+			return
+		}
+		countFmtBytes(env, len(typeName(t))+len("{}"))
+		type entry struct {
+			key reflect.Value
+			val reflect.Value
+			// Set once the key has been formatted for sorting.
+			keyStr    string
+			formatted bool
+		}
+		entries := make([]*entry, 0, v.Len())
+		i := v.MapRange()
+		for i.Next() {
+			entries = append(entries, &entry{key: i.Key(), val: i.Value()})
+		}
+		var k reflect.Value
+		defer annotateKey(env, t, &k)
+		env, paths := enterPaths(env, fmtPathTag{})
+		defer paths.leave()
+		// Keys are only formatted for sorting when they can't be ordered, and
+		// doing so doesn't count towards the limits.  The keys that are shown
+		// are formatted again as part of the value.
+		formatKey := func(e *entry) {
+			if e.formatted {
+				return
+			}
+			k = e.key
+			// Paths can't refer to anything inside keys.
+			paths.skip()
+			bytesBefore := frame.bytes
+			e.keyStr = RenderDoc(env, fmtDoc(env, keys, e.key))
+			e.formatted = true
+			frame.bytes = bytesBefore
+			k = reflect.Value{}
+		}
+		byString := func(a, b *entry) int {
+			formatKey(a)
+			formatKey(b)
+			return strings.Compare(a.keyStr, b.keyStr)
+		}
+		ordEnv := nestedEnv(env)
+		byOrd := func(a, b *entry) int {
+			if c := OrderVals(ordEnv, a.key, b.key); c != 0 {
+				return c
+			}
+			return byString(a, b)
+		}
+		switch mf.Order {
+		case FmtMapOrderAuto:
+			if err := try(func() { slices.SortFunc(entries, byOrd) }); err != nil {
+				slices.SortFunc(entries, byString)
+			}
+		case FmtMapOrderOrd:
+			slices.SortFunc(entries, byOrd)
+		case FmtMapOrderString:
+			slices.SortFunc(entries, byString)
+		default:
+			panic(fmt.Errorf("%w: unknown FmtMapOrder %d", ErrInvalid, mf.Order))
+		}
+		emit(fmtComposite(env, t, len(entries) == 0, func(add func(Doc)) string {
+			for n, e := range entries {
+				if frame.full(n) {
+					return moreString(len(entries) - n)
+				}
+				countFmtBytes(env, len(": ")+fmtSepBytes(n))
+				// The key has to be done with the path stack before the
+				// value's path is looked up.
+				keyDoc := fmtStream(env, func(emit func(Doc)) {
+					k = e.key
+					paths.skip()
+					emit(fmtDoc(env, keys, e.key))
+				})
+				valDoc := fmtStream(env, func(emit func(Doc)) {
+					k = e.key
+					impl := pathChild(paths, keyPath(e.key), vals)
+					emit(fmtDoc(env, impl, e.val))
+				})
+				add(DocConcat(keyDoc, DocText(": "), valDoc))
+			}
+			k = reflect.Value{}
+			return ""
+		}))
+	})
 }
 
 type FmtSlice struct {
 	Elems Fmt
-	// TODO: possibly add an option to limit number of elements?
+	// Overrides the Env's FmtLimits for this value and everything in it.
+	Limits FmtLimits
 }
 
 func (sf FmtSlice) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, sf.FmtDoc(env, v))
+}
+
+func (sf FmtSlice) FmtDoc(env Env, v reflect.Value) Doc {
 	t := v.Type()
 	switch t.Kind() {
 	case reflect.Slice, reflect.Array:
@@ -244,15 +513,43 @@ func (sf FmtSlice) Fmt(env Env, v reflect.Value) string {
 	if elems == nil {
 		elems = FmtDeep{}
 	}
-	elementStrings := make([]string, 0, v.Len())
-	for i := range v.Len() {
-		elem := v.Index(i)
-		elementStrings = append(elementStrings, fmt.Sprintf("%s,", elems.Fmt(env, elem)))
-	}
-	for i := range elementStrings {
-		elementStrings[i] = indent(elementStrings[i])
-	}
-	return fmt.Sprintf("%s{\n%s\n}", t, strings.Join(elementStrings, "\n"))
+	return fmtStream(env, func(emit func(Doc)) {
+		env, s := withState(env)
+		if pos, ok := s.fmt.enter(v, v); !ok {
+			emit(fmtLeaf(env, cycleString(pos)))
+			return
+		}
+		defer s.fmt.leave(v, v)
+		frame, ok, leave := enterFmtLimits(env, s, sf.Limits)
+		defer leave()
+		if !ok {
+			emit(fmtLeaf(env, typeName(t)+"{...}"))
+			return
+		}
+		countFmtBytes(env, len(typeName(t))+len("{}"))
+		env, paths := enterPaths(env, fmtPathTag{})
+		defer paths.leave()
+		idx := -1
+		defer annotateIndex(t, &idx)
+		emit(fmtComposite(env, t, v.Len() == 0, func(add func(Doc)) string {
+			for i := range v.Len() {
+				if frame.full(i) {
+					return moreString(v.Len() - i)
+				}
+				idx = i
+				countFmtBytes(env, fmtSepBytes(i))
+				elem := v.Index(i)
+				add(fmtDoc(env, pathChild(paths, indexPath(i), elems), elem))
+			}
+			return ""
+		}))
+	})
+}
+
+// cycleString refers back to the pos'th reference on the path from the root
+// value, which is being formatted again inside itself.
+func cycleString(pos int) string {
+	return fmt.Sprintf("<cycle #%d>", pos)
 }
 
 type FmtPointer struct {
@@ -260,19 +557,31 @@ type FmtPointer struct {
 }
 
 func (pf FmtPointer) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, pf.FmtDoc(env, v))
+}
+
+func (pf FmtPointer) FmtDoc(env Env, v reflect.Value) Doc {
 	t := v.Type()
 	if t.Kind() != reflect.Pointer {
 		panic(ErrWrongType)
 	}
 	if v.IsNil() {
-		return "<nil>"
+		return fmtLeaf(env, "<nil>")
 	}
 	impl := pf.Elem
 	if impl == nil {
 		impl = FmtDeep{}
 	}
-	elem := v.Elem()
-	return fmt.Sprintf("&%s", impl.Fmt(env, elem))
+	return fmtStream(env, func(emit func(Doc)) {
+		env, s := withState(env)
+		if pos, ok := s.fmt.enter(v, v); !ok {
+			emit(fmtLeaf(env, cycleString(pos)))
+			return
+		}
+		defer s.fmt.leave(v, v)
+		emit(fmtLeaf(env, "&"))
+		emit(fmtDoc(env, impl, v.Elem()))
+	})
 }
 
 type FmtInterface struct {
@@ -280,22 +589,27 @@ type FmtInterface struct {
 }
 
 func (fmtI FmtInterface) Fmt(env Env, v reflect.Value) string {
+	return RenderDoc(env, fmtI.FmtDoc(env, v))
+}
+
+func (fmtI FmtInterface) FmtDoc(env Env, v reflect.Value) Doc {
 	t := v.Type()
 	if t.Kind() != reflect.Interface {
 		panic(ErrWrongType)
 	}
-	var elemStr string
+	countFmtBytes(env, len(typeName(t))+len("()"))
+	var elemDoc Doc
 	if v.IsNil() {
-		elemStr = "nil"
+		elemDoc = fmtLeaf(env, "nil")
 	} else {
 		impl := fmtI.Elem
 		if impl == nil {
 			impl = FmtDeep{}
 		}
 		elem := v.Elem()
-		elemStr = impl.Fmt(env, elem)
+		elemDoc = fmtDoc(env, impl, elem)
 	}
-	return fmt.Sprintf("%s(%s)", typeName(t), elemStr)
+	return DocConcat(DocText(typeName(t)+"("), elemDoc, DocText(")"))
 }
 
 type FmtWrap struct {
@@ -303,7 +617,7 @@ type FmtWrap struct {
 	Then Fmt
 }
 
-func (fw FmtWrap) Fmt(env Env, v reflect.Value) string {
+func (fw FmtWrap) wrap(env Env) (Env, Fmt) {
 	if fw.Opt != nil {
 		env = WrapEnv(env, fw.Opt)
 	}
@@ -311,8 +625,17 @@ func (fw FmtWrap) Fmt(env Env, v reflect.Value) string {
 	if then == nil {
 		then = FmtDeep{}
 	}
+	return env, then
+}
+
+func (fw FmtWrap) Fmt(env Env, v reflect.Value) string {
+	env, then := fw.wrap(env)
 	return then.Fmt(env, v)
+}
 
+func (fw FmtWrap) FmtDoc(env Env, v reflect.Value) Doc {
+	env, then := fw.wrap(env)
+	return fmtDoc(env, then, v)
 }
 
 type FmtStringer struct{}
@@ -328,6 +651,27 @@ func (FmtStringer) Fmt(_ Env, v reflect.Value) string {
 	return str.String()
 }
 
+// FmtGoStringer formats values with their GoString method, which may have a
+// pointer receiver.
+type FmtGoStringer struct{}
+
+func (FmtGoStringer) Fmt(_ Env, v reflect.Value) string {
+	if !v.CanInterface() {
+		return "<uninterfaceable>"
+	}
+	return ifaceFor(v, goStringerType).(fmt.GoStringer).GoString()
+}
+
+// FmtFormatter formats values that implement fmt.Formatter with the %v verb.
+type FmtFormatter struct{}
+
+func (FmtFormatter) Fmt(_ Env, v reflect.Value) string {
+	if !v.CanInterface() {
+		return "<uninterfaceable>"
+	}
+	return fmt.Sprintf("%v", ifaceFor(v, formatterType))
+}
+
 func FmtOpt(typ reflect.Type, fmt Fmt) Opt {
 	return OptFunc(func(e Env) {
 		e.Set(typ, fmtTag{}, fmt)
@@ -353,3 +697,9 @@ func FmtOptFor[T any](typed func(Env, T) string) Opt {
 func FmtOptStringer[T fmt.Stringer]() Opt {
 	return FmtOpt(reflect.TypeFor[T](), FmtStringer{})
 }
+
+// FmtOptMethods makes the default Fmt use FmtGoStringer or FmtFormatter for
+// any type that has the corresponding methods, in that order of preference.
+func FmtOptMethods() Opt {
+	return flagOpt(fmtMethodsTag{})
+}
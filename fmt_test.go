@@ -1,8 +1,12 @@
 package ops_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 	"unsafe"
 
@@ -211,6 +215,50 @@ func TestFormat(t *testing.T) {
 				},
 				want: `&"hello"`, // TODO: I don't like the way this looks.
 			},
+			{
+				name: "Cycle",
+				f: func() string {
+					type Node struct {
+						Val  int
+						Next *Node
+					}
+					a := &Node{Val: 1}
+					a.Next = &Node{Val: 2, Next: a}
+					return ops.Format(nil, a)
+				},
+				want: `&ops_test.Node{
+  Val: 1,
+  Next: &ops_test.Node{
+    Val: 2,
+    Next: <cycle #1>,
+  },
+}`,
+			},
+			{
+				name: "Shared Pointer Is Not A Cycle",
+				f: func() string {
+					type Pair struct {
+						A, B *int
+					}
+					i := 1
+					return ops.Format(nil, Pair{A: &i, B: &i})
+				},
+				want: `ops_test.Pair{
+  A: &1,
+  B: &1,
+}`,
+			},
+			{
+				name: "Empty Values",
+				f: func() string {
+					type Outer struct {
+						Items []int
+						Inner struct{}
+					}
+					return ops.Format(nil, Outer{Items: []int{}})
+				},
+				want: "ops_test.Outer{\n  Items: []int{\n  \n  },\n  Inner: struct {}{\n  \n  },\n}",
+			},
 			{
 				name: "Uintptr",
 				f: func() string {
@@ -230,6 +278,76 @@ func TestFormat(t *testing.T) {
 		}
 	})
 
+	t.Run("Map Order", func(t *testing.T) {
+		tests := []struct {
+			name string
+			opt  ops.Opt
+			f    func(ops.Env) string
+			want string
+		}{
+			{
+				name: "Ordered Keys",
+				f: func(env ops.Env) string {
+					return ops.Format(env, map[int]bool{10: true, 2: false, 1: true})
+				},
+				want: `map[int]bool{
+  1: true,
+  2: false,
+  10: true,
+}`,
+			},
+			{
+				name: "Unorderable Keys Fall Back To Strings",
+				f: func(env ops.Env) string {
+					return ops.Format(env, map[bool]int{true: 1, false: 0})
+				},
+				want: `map[bool]int{
+  false: 0,
+  true: 1,
+}`,
+			},
+			{
+				name: "Registered Ord",
+				opt: ops.OrdOpt(reflect.TypeFor[string](), ops.OrdFunc(func(_ ops.Env, v1, v2 reflect.Value) int {
+					return len(v1.String()) - len(v2.String())
+				})),
+				f: func(env ops.Env) string {
+					return ops.Format(env, map[string]int{"ccc": 3, "a": 1, "bb": 2})
+				},
+				want: `map[string]int{
+  "a": 1,
+  "bb": 2,
+  "ccc": 3,
+}`,
+			},
+			{
+				name: "String Order",
+				opt:  ops.FmtOpt(reflect.TypeFor[map[int]bool](), ops.FmtMap{Order: ops.FmtMapOrderString}),
+				f: func(env ops.Env) string {
+					return ops.Format(env, map[int]bool{10: true, 2: false, 1: true})
+				},
+				want: `map[int]bool{
+  1: true,
+  10: true,
+  2: false,
+}`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				env := ops.NewEnv()
+				if tt.opt != nil {
+					env = ops.WrapEnv(env, tt.opt)
+				}
+				for range 10 {
+					if got := tt.f(env); got != tt.want {
+						t.Fatalf("got %q, want %q", got, tt.want)
+					}
+				}
+			})
+		}
+	})
+
 	t.Run("With FmtStruct", func(t *testing.T) {
 		type Person struct {
 			Name string
@@ -261,6 +379,34 @@ func TestFormat(t *testing.T) {
     Age: int(...),
   },
   ...
+}`,
+			},
+			{
+				name: "Include Unexported",
+				opt:  ops.FmtOpt(reflect.TypeFor[Animal](), ops.FmtStruct{IncludeUnexported: true}),
+				want: `ops_test.Animal{
+  Species: "Dog",
+  OwnedBy: &ops_test.Person{
+    Name: "Bob",
+    Age: 25,
+  },
+  isGood: true,
+}`,
+			},
+			{
+				name: "Unexported Field Override",
+				opt: ops.FmtOpt(reflect.TypeFor[Animal](), ops.FmtStruct{
+					Fields: map[ops.Field]ops.Fmt{
+						ops.NamedField("isGood"): ops.FmtElide{},
+					},
+				}),
+				want: `ops_test.Animal{
+  Species: "Dog",
+  OwnedBy: &ops_test.Person{
+    Name: "Bob",
+    Age: 25,
+  },
+  isGood: bool(...),
 }`,
 			},
 		}
@@ -274,4 +420,300 @@ func TestFormat(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Compact", func(t *testing.T) {
+		type Inner struct {
+			Tags []string
+		}
+		type Outer struct {
+			Name   string
+			Inner  *Inner
+			Counts map[string]int
+			hidden bool
+		}
+		v := Outer{Name: "x", Inner: &Inner{Tags: []string{"a", "b"}}, Counts: map[string]int{"b": 2, "a": 1}}
+		tests := []struct {
+			name string
+			opt  ops.Opt
+			want string
+		}{
+			{
+				name: "Defaults",
+				want: `ops_test.Outer{Name: "x", Inner: &ops_test.Inner{Tags: []string{"a", "b"}}, Counts: map[string]int{"a": 1, "b": 2}, ...}`,
+			},
+			{
+				name: "Overrides",
+				opt: ops.Opts{
+					ops.FmtOpt(reflect.TypeFor[[]string](), ops.FmtElide{}),
+					ops.FmtOpt(reflect.TypeFor[Outer](), ops.FmtStruct{
+						Fields: map[ops.Field]ops.Fmt{
+							ops.NamedField("Counts"): ops.FmtElide{},
+						},
+					}),
+				},
+				want: `ops_test.Outer{Name: "x", Inner: &ops_test.Inner{Tags: []string(...)}, Counts: map[string]int(...), ...}`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				opts := ops.Opts{ops.FmtOptCompact()}
+				if tt.opt != nil {
+					opts = append(opts, tt.opt)
+				}
+				env := ops.WrapEnv(ops.NewEnv(), opts...)
+				if got := ops.Format(env, v); got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		type Node struct {
+			Name     string
+			Children []Node
+		}
+		tree := Node{Name: "root", Children: []Node{{Name: "a", Children: []Node{{Name: "a1"}}}}}
+		big := make([]int, 10000)
+		tests := []struct {
+			name   string
+			limits ops.FmtLimits
+			opt    ops.Opt
+			v      any
+			want   string
+		}{
+			{
+				name:   "Elems",
+				limits: ops.FmtLimits{Elems: 2},
+				v:      []int{1, 2, 3, 4},
+				want:   `[]int{1, 2, ... (2 more)}`,
+			},
+			{
+				name:   "Map Elems",
+				limits: ops.FmtLimits{Elems: 1},
+				v:      map[string]int{"b": 2, "a": 1, "c": 3},
+				want:   `map[string]int{"a": 1, ... (2 more)}`,
+			},
+			{
+				name:   "String",
+				limits: ops.FmtLimits{String: 5},
+				v:      []string{"hello world", "hi"},
+				want:   `[]string{"hello"... (6 more), "hi"}`,
+			},
+			{
+				name:   "String Rune Boundary",
+				limits: ops.FmtLimits{String: 2},
+				v:      []string{"héllo"},
+				want:   `[]string{"h"... (5 more)}`,
+			},
+			{
+				name:   "Depth",
+				limits: ops.FmtLimits{Depth: 2},
+				v:      tree,
+				want:   `ops_test.Node{Name: "root", Children: []ops_test.Node{ops_test.Node{...}}}`,
+			},
+			{
+				name:   "Bytes",
+				limits: ops.FmtLimits{Bytes: 16},
+				v:      big,
+				want:   `[]int{0, 0, 0, 0, ... (9996 more)}`,
+			},
+			{
+				name:   "Bytes Include Field Names",
+				limits: ops.FmtLimits{Bytes: 27},
+				v:      tree,
+				want:   `ops_test.Node{Name: "root", ... (1 more)}`,
+			},
+			{
+				name:   "Bytes Cut Strings",
+				limits: ops.FmtLimits{Bytes: 30},
+				v:      []string{strings.Repeat("x", 1000)},
+				want:   `[]string{"xxxxxxxxxxxxxxxxxxxx"... (980 more)}`,
+			},
+			{
+				name:   "Bytes Cut Bare String",
+				limits: ops.FmtLimits{Bytes: 10},
+				v:      strings.Repeat("x", 1000),
+				want:   `"xxxxxxxxxx"... (990 more)`,
+			},
+			{
+				name:   "Bytes Cut Other Values",
+				limits: ops.FmtLimits{Bytes: 20},
+				opt: ops.FmtOpt(reflect.TypeFor[int](), ops.FmtFunc(func(ops.Env, reflect.Value) string {
+					return strings.Repeat("y", 100)
+				})),
+				v:    []int{1},
+				want: `[]int{yyyyyyyyyyyyy... (87 more)}`,
+			},
+			{
+				name:   "Bytes Cut FmtWriter",
+				limits: ops.FmtLimits{Bytes: 20},
+				opt:    ops.FmtOpt(reflect.TypeFor[blob](), fmtBlob{}),
+				v:      []blob{blob(strings.Repeat("z", 100))},
+				want:   `[]ops_test.blob{zzz... (97 more)}`,
+			},
+			{
+				name:   "Bytes Cut Bare FmtWriter",
+				limits: ops.FmtLimits{Bytes: 10},
+				opt:    ops.FmtOpt(reflect.TypeFor[blob](), fmtBlob{}),
+				v:      blob(strings.Repeat("z", 100)),
+				want:   `zzzzzzzzzz... (90 more)`,
+			},
+			{
+				name: "Per Value",
+				opt: ops.FmtOpt(reflect.TypeFor[[]int](), ops.FmtSlice{
+					Limits: ops.FmtLimits{Elems: 1},
+				}),
+				v:    [][]int{{1, 2}, {3}},
+				want: `[][]int{[]int{1, ... (1 more)}, []int{3}}`,
+			},
+			{
+				name:   "Per Value Overrides Env",
+				limits: ops.FmtLimits{Elems: 1},
+				opt: ops.FmtOpt(reflect.TypeFor[[][]int](), ops.FmtSlice{
+					Limits: ops.FmtLimits{Elems: 3},
+				}),
+				v:    [][]int{{1, 2}, {3}},
+				want: `[][]int{[]int{1, 2}, []int{3}}`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				opts := ops.Opts{ops.FmtOptCompact(), ops.FmtOptLimits(tt.limits)}
+				if tt.opt != nil {
+					opts = append(opts, tt.opt)
+				}
+				env := ops.WrapEnv(ops.NewEnv(), opts...)
+				if got := ops.FormatVal(env, reflect.ValueOf(tt.v)); got != tt.want {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("Limits Format Only Shown Map Keys", func(t *testing.T) {
+		m := make(map[int]int, 1000)
+		for i := range 1000 {
+			m[i] = i
+		}
+		var formatted int
+		env := ops.WrapEnv(ops.NewEnv(),
+			ops.FmtOptCompact(),
+			ops.FmtOptLimits(ops.FmtLimits{Elems: 2}),
+			ops.FmtOpt(reflect.TypeFor[map[int]int](), ops.FmtMap{
+				Keys: ops.FmtFunc(func(env ops.Env, v reflect.Value) string {
+					formatted++
+					return ops.FormatVal(env, v)
+				}),
+			}))
+		want := `map[int]int{0: 0, 1: 1, ... (998 more)}`
+		if got := ops.Format(env, m); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if formatted != 2 {
+			t.Errorf("formatted %d keys, want 2", formatted)
+		}
+	})
+
+	t.Run("FormatTo", func(t *testing.T) {
+		type Doc struct {
+			Title string
+			Body  blob
+			Tags  []string
+		}
+		d := Doc{Title: "t", Body: blob("some\ntext"), Tags: []string{"a", "b"}}
+		env := ops.WrapEnv(ops.NewEnv(), ops.FmtOpt(reflect.TypeFor[blob](), fmtBlob{}))
+
+		var buf bytes.Buffer
+		if err := ops.FormatTo(&buf, env, d); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		want := `ops_test.Doc{
+  Title: "t",
+  Body: some
+  text,
+  Tags: []string{
+    "a",
+    "b",
+  },
+}`
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got := ops.Format(env, d); got != want {
+			t.Errorf("Format got %q, want %q", got, want)
+		}
+
+		buf.Reset()
+		if err := ops.FormatTo(&buf, env, blob("streamed")); err != nil || buf.String() != "streamed" {
+			t.Errorf("got %q, %v, want %q", buf.String(), err, "streamed")
+		}
+
+		errWrite := errors.New("write failed")
+		if err := ops.FormatTo(failingWriter{errWrite}, env, d); !errors.Is(err, errWrite) {
+			t.Errorf("got error %v, want %v", err, errWrite)
+		}
+
+		boolStringer := ops.WrapEnv(ops.NewEnv(), ops.FmtOpt(reflect.TypeFor[bool](), ops.FmtStringer{}))
+		if err := ops.FormatTo(io.Discard, boolStringer, []bool{true}); !errors.Is(err, ops.ErrWrongType) {
+			t.Errorf("got error %v, want %v", err, ops.ErrWrongType)
+		}
+	})
+
+	t.Run("FormatTo Streams", func(t *testing.T) {
+		var buf bytes.Buffer
+		impl := &fmtStreamed{written: buf.Len}
+		env := ops.WrapEnv(ops.NewEnv(), ops.FmtOpt(reflect.TypeFor[blob](), impl))
+		blobs := []blob{blob(strings.Repeat("x", 10000)), "y"}
+		if err := ops.FormatTo(&buf, env, blobs); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if len(impl.seen) != 2 || impl.seen[0] != 0 || impl.seen[1] < 10000 {
+			t.Errorf("FmtTo was called after %v bytes were written, want the first blob written before the second is formatted", impl.seen)
+		}
+		want := "[]ops_test.blob{\n  " + string(blobs[0]) + ",\n  y,\n}"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+type blob string
+
+// fmtBlob writes blobs without quoting them.
+type fmtBlob struct{}
+
+func (f fmtBlob) Fmt(env ops.Env, v reflect.Value) string {
+	var sb strings.Builder
+	_ = f.FmtTo(&sb, env, v)
+	return sb.String()
+}
+
+func (fmtBlob) FmtTo(w io.Writer, _ ops.Env, v reflect.Value) error {
+	_, err := io.WriteString(w, v.String())
+	return err
+}
+
+// fmtStreamed records how much had been written when each value was
+// formatted.
+type fmtStreamed struct {
+	written func() int
+	seen    []int
+}
+
+func (*fmtStreamed) Fmt(ops.Env, reflect.Value) string {
+	panic("Fmt called instead of FmtTo")
+}
+
+func (f *fmtStreamed) FmtTo(w io.Writer, env ops.Env, v reflect.Value) error {
+	f.seen = append(f.seen, f.written())
+	return fmtBlob{}.FmtTo(w, env, v)
+}
+
+type failingWriter struct {
+	err error
+}
+
+func (fw failingWriter) Write([]byte) (int, error) {
+	return 0, fw.err
 }
@@ -0,0 +1,153 @@
+package ops
+
+import "reflect"
+
+// Freeze returns a read-only snapshot of env, whose Set and SetAll panic with
+// ErrFrozen.  Later changes to the layers of env made by this package don't
+// affect the snapshot.  Env implementations from elsewhere can't be copied,
+// so if env is, or is wrapped around, one of those, the snapshot keeps
+// looking values up in it and sees any later changes to it.
+//
+// The layers of a frozen Env are merged into one persistent map, so looking
+// values up in it, or in an Env derived from it with WrapEnv, doesn't walk a
+// chain of layers.  Freezing a derived Env shares that map with the frozen
+// Env it was derived from, copying only the parts that the new layers change,
+// so its cost grows with the size of those layers rather than with everything
+// registered below them.
+func Freeze(env Env) Env {
+	if env == nil {
+		env = NewEnv()
+	}
+	if fe, ok := env.(*frozenEnv); ok {
+		return fe
+	}
+	data, rest := flatten(env)
+	return &frozenEnv{data: data, rest: rest}
+}
+
+type frozenEnv struct {
+	data pmap[Tag, typeToVal]
+	// Any part of the original Env that couldn't be flattened, or nil.
+	rest Env
+}
+
+func (e *frozenEnv) Set(reflect.Type, Tag, Val) {
+	panic(ErrFrozen)
+}
+
+func (e *frozenEnv) SetAll(Tag, Val) {
+	panic(ErrFrozen)
+}
+
+func (e *frozenEnv) Get(typ reflect.Type, tag Tag) (Val, bool) {
+	if typ == nil {
+		panic(ErrNilType)
+	}
+	if tag == nil {
+		panic(ErrNilTag)
+	}
+	if ttv, ok := e.data.get(tag); ok {
+		if val, ok := ttv.Get(typ); ok {
+			return val, true
+		}
+	}
+	if e.rest != nil {
+		return e.rest.Get(typ, tag)
+	}
+	return nil, false
+}
+
+// trieTypeToVal holds the values registered with Set in a frozen Env.
+type trieTypeToVal struct {
+	types pmap[reflect.Type, Val]
+}
+
+func (t trieTypeToVal) Get(typ reflect.Type) (Val, bool) {
+	return t.types.get(typ)
+}
+
+// with returns a copy of t with the values in m added.
+func (t trieTypeToVal) with(m mapTypeToVal) trieTypeToVal {
+	for typ, val := range m {
+		t.types = t.types.with(typ, val)
+	}
+	return t
+}
+
+// layeredTypeToVal results from flattening a layer that used Set over one
+// that used SetAll.
+type layeredTypeToVal struct {
+	types trieTypeToVal
+	all   valForAllTypes
+}
+
+func (l layeredTypeToVal) Get(typ reflect.Type) (Val, bool) {
+	if val, ok := l.types.Get(typ); ok {
+		return val, true
+	}
+	return l.all.Get(typ)
+}
+
+// flatten returns the values in env merged into a pmap, along with any part
+// of env that it couldn't see into.
+func flatten(env Env) (pmap[Tag, typeToVal], Env) {
+	switch env := env.(type) {
+	case *frozenEnv:
+		return env.data, env.rest
+	case *syncEnv:
+		return mergeLayer(pmap[Tag, typeToVal]{}, *env.load()), nil
+	case *mapEnv:
+		return mergeLayer(pmap[Tag, typeToVal]{}, *env), nil
+	case *wrappedEnv:
+		parent, rest := flatten(env.parent)
+		return mergeLayer(parent, *env.data), rest
+	default:
+		return pmap[Tag, typeToVal]{}, env
+	}
+}
+
+// stackedTypeToVal results from flattening a layer over another when their
+// values can't be merged.
+type stackedTypeToVal struct {
+	top, below typeToVal
+}
+
+func (s stackedTypeToVal) Get(typ reflect.Type) (Val, bool) {
+	if val, ok := s.top.Get(typ); ok {
+		return val, true
+	}
+	return s.below.Get(typ)
+}
+
+// mergeLayer returns a copy of parent with the values in layer, which may be
+// modified later, layered over it.  parent itself is left as it is.
+func mergeLayer(parent pmap[Tag, typeToVal], layer mapEnv) pmap[Tag, typeToVal] {
+	out := parent
+	for tag, ttv := range layer {
+		below, _ := parent.get(tag)
+		switch ttv := ttv.(type) {
+		case mapTypeToVal:
+			switch below := below.(type) {
+			case nil:
+				out = out.with(tag, trieTypeToVal{}.with(ttv))
+			case trieTypeToVal:
+				out = out.with(tag, below.with(ttv))
+			case valForAllTypes:
+				out = out.with(tag, layeredTypeToVal{types: trieTypeToVal{}.with(ttv), all: below})
+			case layeredTypeToVal:
+				out = out.with(tag, layeredTypeToVal{types: below.types.with(ttv), all: below.all})
+			default:
+				out = out.with(tag, stackedTypeToVal{top: trieTypeToVal{}.with(ttv), below: below})
+			}
+		case *ruleTypeToVal:
+			if below == nil {
+				out = out.with(tag, ttv.clone())
+			} else {
+				out = out.with(tag, stackedTypeToVal{top: ttv.clone(), below: below})
+			}
+		default:
+			out = out.with(tag, ttv)
+		}
+	}
+	return out
+}
@@ -0,0 +1,309 @@
+package ops
+
+import (
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+type Hasher interface {
+	Hash(Env, *maphash.Hash, reflect.Value)
+}
+
+type hashTag struct{}
+
+var hashSeed = maphash.MakeSeed()
+
+// Cyclic values that are equal under Eq can still have different shapes (a
+// ring of one node is equal to a ring of two identical nodes), so rather than
+// marking cycles, hashing stops following references past a fixed depth.
+const hashRefDepth = 8
+
+func HashVals(env Env, v reflect.Value) uint64 {
+	if !v.IsValid() {
+		panic(ErrInvalid)
+	}
+	var h maphash.Hash
+	h.SetSeed(hashSeed)
+	hasherFor(env, v.Type()).Hash(env, &h, v)
+	return h.Sum64()
+}
+
+func Hash[T any](env Env, in T) uint64 {
+	v := ValueFor(in)
+	return HashVals(env, v)
+}
+
+func TryHashVals(env Env, v reflect.Value) (uint64, error) {
+	var result uint64
+	err := try(func() {
+		result = HashVals(env, v)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func TryHash[T any](env Env, in T) (uint64, error) {
+	var result uint64
+	err := try(func() {
+		result = Hash(env, in)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func hasherFor(env Env, typ reflect.Type) Hasher {
+	if env != nil {
+		if anyVal, ok := env.Get(typ, hashTag{}); ok {
+			if impl := anyVal.(Hasher); impl != nil {
+				return impl
+			}
+		}
+	}
+	return hasherForEq(eqFor(env, typ))
+}
+
+// Eq implementations that also implement Hasher hash consistently with
+// themselves.  Any other Eq might consider any two values equal, so the only
+// consistent choice is to hash nothing.
+func hasherForEq(eq Eq) Hasher {
+	if impl, ok := eq.(Hasher); ok {
+		return impl
+	}
+	return HashNone{}
+}
+
+type HashFunc func(Env, *maphash.Hash, reflect.Value)
+
+func (f HashFunc) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	f(env, h, v)
+}
+
+type HashNone struct{}
+
+func (HashNone) Hash(_ Env, _ *maphash.Hash, _ reflect.Value) {}
+
+type HashDeep struct{}
+
+func (HashDeep) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	hasherFor(env, v.Type()).Hash(env, h, v)
+}
+
+func (EqDeep) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	HashDeep{}.Hash(env, h, v)
+}
+
+func (EqTrue) Hash(_ Env, _ *maphash.Hash, _ reflect.Value) {}
+
+func hashFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		// Make sure that 0 and -0 hash the same.
+		f = 0
+	}
+	maphash.WriteComparable(h, math.Float64bits(f))
+}
+
+func (EqDefault) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	t := v.Type()
+	if useEqMethod(env, t) {
+		// Nothing is known about how Equal methods treat values.
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		EqStruct{}.Hash(env, h, v)
+	case reflect.Map:
+		EqMap{}.Hash(env, h, v)
+	case reflect.Slice, reflect.Array:
+		EqSlice{}.Hash(env, h, v)
+	case reflect.Ptr:
+		EqPointer{}.Hash(env, h, v)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		panic(fmt.Errorf("%w: cannot hash type %s", ErrInvalid, typeName(t)))
+	case reflect.Complex128, reflect.Complex64:
+		c := v.Complex()
+		hashFloat(h, real(c))
+		hashFloat(h, imag(c))
+	case reflect.Float32, reflect.Float64:
+		hashFloat(h, v.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		maphash.WriteComparable(h, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		maphash.WriteComparable(h, v.Uint())
+	case reflect.String:
+		// Unlike WriteString, this includes the length, so that adjacent
+		// strings like "ab", "" and "a", "b" hash differently.
+		maphash.WriteComparable(h, v.String())
+	case reflect.Bool:
+		maphash.WriteComparable(h, v.Bool())
+	case reflect.Interface:
+		EqInterface{}.Hash(env, h, v)
+	default:
+		panic(fmt.Errorf("%w: unsupported kind %v for value %v", ErrInternal, t.Kind(), v))
+	}
+}
+
+// enterHashRef returns false if v is a reference that is nested too deeply to
+// be hashed.
+func enterHashRef(env Env, v reflect.Value) (Env, bool, func()) {
+	if _, _, ok := refOf(v); !ok {
+		return env, true, func() {}
+	}
+	env, s := withState(env)
+	if s.hashDepth >= hashRefDepth {
+		return env, false, nil
+	}
+	s.hashDepth++
+	return env, true, func() { s.hashDepth-- }
+}
+
+func (cp EqPointer) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	if v.Kind() != reflect.Ptr {
+		panic(ErrWrongType)
+	}
+	if cp.ByAddr {
+		maphash.WriteComparable(h, v.Pointer())
+		return
+	}
+	maphash.WriteComparable(h, v.IsNil())
+	if v.IsNil() {
+		return
+	}
+	env, ok, leave := enterHashRef(env, v)
+	if !ok {
+		return
+	}
+	defer leave()
+	elem := cp.Elem
+	if elem == nil {
+		elem = EqDeep{}
+	}
+	hasherForEq(elem).Hash(env, h, v.Elem())
+}
+
+func (ci EqInterface) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	if v.Kind() != reflect.Interface {
+		panic(ErrWrongType)
+	}
+	maphash.WriteComparable(h, v.IsNil())
+	if v.IsNil() {
+		return
+	}
+	e := v.Elem()
+	maphash.WriteComparable(h, e.Type())
+	elem := ci.Elem
+	if elem == nil {
+		elem = EqDeep{}
+	}
+	hasherForEq(elem).Hash(env, h, e)
+}
+
+func (cs EqStruct) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	t := v.Type()
+	if t.Kind() != reflect.Struct {
+		panic(ErrWrongType)
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	for f, impl := range cs.fields(t) {
+		impl = pathChild(paths, fieldPath(f.Name), impl)
+		hasherForEq(impl).Hash(env, h, v.Field(f.Index[0]))
+	}
+}
+
+func (cs EqSlice) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		// ok
+	default:
+		panic(ErrWrongType)
+	}
+	maphash.WriteComparable(h, v.Len())
+	env, ok, leave := enterHashRef(env, v)
+	if !ok {
+		return
+	}
+	defer leave()
+	elems := cs.Elems
+	if elems == nil {
+		elems = EqDeep{}
+	}
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	if !cs.Unordered {
+		for elemNum := range v.Len() {
+			impl := pathChild(paths, indexPath(elemNum), elems)
+			hasherForEq(impl).Hash(env, h, v.Index(elemNum))
+		}
+		return
+	}
+	// Equal elements can be at different indices, so if path options apply
+	// to some of them, only the length is hashed.
+	if paths.pending() {
+		return
+	}
+	hasher := hasherForEq(elems)
+	// Elements are hashed separately and summed so that order doesn't matter.
+	var sum uint64
+	for elemNum := range v.Len() {
+		var elem maphash.Hash
+		elem.SetSeed(h.Seed())
+		hasher.Hash(env, &elem, v.Index(elemNum))
+		sum += elem.Sum64()
+	}
+	maphash.WriteComparable(h, sum)
+}
+
+func (cm EqMap) Hash(env Env, h *maphash.Hash, v reflect.Value) {
+	if v.Kind() != reflect.Map {
+		panic(ErrWrongType)
+	}
+	maphash.WriteComparable(h, v.Len())
+	env, ok, leave := enterHashRef(env, v)
+	if !ok {
+		return
+	}
+	defer leave()
+	keys := cm.Keys
+	if keys == nil {
+		keys = EqDeep{}
+	}
+	vals := cm.Vals
+	if vals == nil {
+		vals = EqDeep{}
+	}
+	keyHasher := hasherForEq(keys)
+	env, paths := enterPaths(env, eqPathTag{})
+	defer paths.leave()
+	// Entries are hashed separately and summed so that iteration order
+	// doesn't matter.
+	var sum uint64
+	i := v.MapRange()
+	for i.Next() {
+		var entry maphash.Hash
+		entry.SetSeed(h.Seed())
+		paths.skip()
+		keyHasher.Hash(env, &entry, i.Key())
+		valHasher := hasherForEq(pathChild(paths, keyPath(i.Key()), vals))
+		valHasher.Hash(env, &entry, i.Value())
+		sum += entry.Sum64()
+	}
+	maphash.WriteComparable(h, sum)
+}
+
+func HashOpt(t reflect.Type, hasher Hasher) Opt {
+	return OptFunc(func(env Env) {
+		env.Set(t, hashTag{}, hasher)
+	})
+}
+
+func HashOptAll(hasher Hasher) Opt {
+	return OptFunc(func(env Env) {
+		env.SetAll(hashTag{}, hasher)
+	})
+}
@@ -0,0 +1,140 @@
+package ops_test
+
+import (
+	"errors"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+func TestHash(t *testing.T) {
+	type Record struct {
+		ID      int
+		Tags    []string
+		Attrs   map[string]float64
+		Updated int64
+	}
+	ignoreUpdated := ops.EqOpt(reflect.TypeFor[Record](), ops.EqStruct{
+		Fields: map[ops.Field]ops.Eq{
+			ops.NamedField("Updated"): ops.EqTrue{},
+		},
+	})
+
+	t.Run("Equal Values Hash Equally", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			opt    ops.Opt
+			v1, v2 any
+		}{
+			{
+				name: "Struct",
+				v1:   Record{ID: 1, Tags: []string{"a"}, Attrs: map[string]float64{"x": 1, "y": 2}},
+				v2:   Record{ID: 1, Tags: []string{"a"}, Attrs: map[string]float64{"y": 2, "x": 1}},
+			},
+			{
+				name: "Ignored Field",
+				opt:  ignoreUpdated,
+				v1:   Record{ID: 1, Updated: 100},
+				v2:   Record{ID: 1, Updated: 200},
+			},
+			{
+				name: "Negative Zero",
+				v1:   0.0,
+				v2:   math.Copysign(0, -1),
+			},
+			{
+				name: "Pointers",
+				v1:   &Record{ID: 1},
+				v2:   &Record{ID: 1},
+			},
+			{
+				name: "Custom Eq Without Hasher",
+				opt: ops.EqOpt(reflect.TypeFor[string](), ops.EqOptFunc(func(_ ops.Env, v1, v2 reflect.Value) bool {
+					return len(v1.String()) == len(v2.String())
+				})),
+				v1: "abc",
+				v2: "xyz",
+			},
+			{
+				name: "Rings Of Different Lengths",
+				v1:   newRing(1),
+				v2:   newRing(1, 1),
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				env := ops.NewEnv()
+				if tt.opt != nil {
+					env = ops.WrapEnv(env, tt.opt)
+				}
+				v1 := reflect.ValueOf(tt.v1)
+				v2 := reflect.ValueOf(tt.v2)
+				if !ops.EqualVals(env, v1, v2) {
+					t.Fatal("values are not equal")
+				}
+				if h1, h2 := ops.HashVals(env, v1), ops.HashVals(env, v2); h1 != h2 {
+					t.Errorf("hashes differ: %x != %x", h1, h2)
+				}
+			})
+		}
+	})
+
+	t.Run("Different Values Hash Differently", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			v1, v2 any
+		}{
+			{
+				name: "Struct Field",
+				v1:   Record{ID: 1},
+				v2:   Record{ID: 2},
+			},
+			{
+				name: "Slice Element",
+				v1:   []string{"a", "b"},
+				v2:   []string{"a", "c"},
+			},
+			{
+				name: "Map Value",
+				v1:   map[string]int{"a": 1},
+				v2:   map[string]int{"a": 2},
+			},
+			{
+				name: "String Boundaries",
+				v1:   [2]string{"ab", ""},
+				v2:   [2]string{"a", "b"},
+			},
+			{
+				name: "Interface Dynamic Type",
+				v1:   []any{1},
+				v2:   []any{int64(1)},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if h1, h2 := ops.Hash(nil, tt.v1), ops.Hash(nil, tt.v2); h1 == h2 {
+					t.Errorf("hashes are both %x", h1)
+				}
+			})
+		}
+	})
+
+	t.Run("HashOpt", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.HashOpt(reflect.TypeFor[string](), ops.HashFunc(func(_ ops.Env, h *maphash.Hash, v reflect.Value) {
+			h.WriteString("constant")
+		})))
+		if ops.Hash(env, "a") != ops.Hash(env, "b") {
+			t.Error("registered Hasher was not used")
+		}
+	})
+
+	t.Run("Unhashable", func(t *testing.T) {
+		_, err := ops.TryHash(nil, func() {})
+		if !errors.Is(err, ops.ErrInvalid) {
+			t.Errorf("got error %v, want %v", err, ops.ErrInvalid)
+		}
+	})
+}
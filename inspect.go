@@ -0,0 +1,233 @@
+package ops
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// EnvEntry is a value registered in an Env.
+type EnvEntry struct {
+	// Nil for values registered with SetAll or for a rule.
+	Type reflect.Type
+	Tag  Tag
+	Val  Val
+	// Describes the rule, like "kind func", for values registered with
+	// KindOpt, PredOpt, or ImplementsOpt.
+	Rule string
+}
+
+func (e EnvEntry) String() string {
+	if e.Rule != "" {
+		return fmt.Sprintf("SetAll(%s) for %s = %v", tagString(e.Tag), e.Rule, e.Val)
+	}
+	if e.Type == nil {
+		return fmt.Sprintf("SetAll(%s) = %v", tagString(e.Tag), e.Val)
+	}
+	return fmt.Sprintf("Set(%s, %s) = %v", typeName(e.Type), tagString(e.Tag), e.Val)
+}
+
+// Tags are often empty structs, which are only told apart by their types.
+func tagString(tag Tag) string {
+	if t := reflect.TypeOf(tag); t.Kind() == reflect.Struct && t.NumField() == 0 {
+		return t.String()
+	}
+	return fmt.Sprintf("%#v", tag)
+}
+
+// EnvLayer is one of the layers that an Env looks values up in.
+type EnvLayer struct {
+	// Describes where the layer came from, like "WrapEnv".
+	Name    string
+	Entries []EnvEntry
+	// Set if the layer is an Env whose entries can't be listed.
+	Opaque bool
+	lookup func(reflect.Type, Tag) (EnvEntry, bool)
+}
+
+// EnvInspector is implemented by Envs that can list what was registered in
+// them.
+type EnvInspector interface {
+	Env
+	// EnvLayers returns the layers that Get consults, in order.
+	EnvLayers() []EnvLayer
+}
+
+// EnvLayers returns the layers that env's Get consults, in order.
+func EnvLayers(env Env) []EnvLayer {
+	if ei, ok := env.(EnvInspector); ok {
+		return ei.EnvLayers()
+	}
+	return []EnvLayer{{
+		Name:   fmt.Sprintf("%T", env),
+		Opaque: true,
+		lookup: func(typ reflect.Type, tag Tag) (EnvEntry, bool) {
+			val, ok := env.Get(typ, tag)
+			return EnvEntry{Tag: tag, Val: val}, ok
+		},
+	}}
+}
+
+// Explanation describes where Get found a value.
+type Explanation struct {
+	// The index in EnvLayers of the layer where the value was found.
+	Layer int
+	// The name of that layer.
+	LayerName string
+	// Type is nil if the value was registered with SetAll or for a rule, or if
+	// it came from an opaque layer.
+	Entry EnvEntry
+}
+
+func (x Explanation) String() string {
+	return fmt.Sprintf("layer %d (%s): %s", x.Layer, x.LayerName, x.Entry)
+}
+
+// Explain reports which layer of env, and which registration in it, supplies
+// the value that env.Get(typ, tag) returns.
+func Explain(env Env, typ reflect.Type, tag Tag) (Explanation, bool) {
+	if typ == nil {
+		panic(ErrNilType)
+	}
+	if tag == nil {
+		panic(ErrNilTag)
+	}
+	for i, layer := range EnvLayers(env) {
+		if e, ok := layer.lookup(typ, tag); ok {
+			return Explanation{Layer: i, LayerName: layer.Name, Entry: e}, true
+		}
+	}
+	return Explanation{}, false
+}
+
+// explainTypeToVal finds the entry in ttv that its Get would use for typ.
+func explainTypeToVal(ttv typeToVal, typ reflect.Type, tag Tag) (EnvEntry, bool) {
+	switch ttv := ttv.(type) {
+	case mapTypeToVal:
+		if val, ok := ttv[typ]; ok {
+			return EnvEntry{Type: typ, Tag: tag, Val: val}, true
+		}
+	case trieTypeToVal:
+		if val, ok := ttv.Get(typ); ok {
+			return EnvEntry{Type: typ, Tag: tag, Val: val}, true
+		}
+	case valForAllTypes:
+		return EnvEntry{Tag: tag, Val: ttv.val}, true
+	case layeredTypeToVal:
+		if e, ok := explainTypeToVal(ttv.types, typ, tag); ok {
+			return e, true
+		}
+		return explainTypeToVal(ttv.all, typ, tag)
+	case *ruleTypeToVal:
+		if e, ok := explainTypeToVal(ttv.types, typ, tag); ok {
+			return e, true
+		}
+		if rv, ok := ttv.match(typ); ok {
+			return EnvEntry{Tag: tag, Val: rv.val, Rule: rv.rule.desc}, true
+		}
+	case stackedTypeToVal:
+		if e, ok := explainTypeToVal(ttv.top, typ, tag); ok {
+			return e, true
+		}
+		return explainTypeToVal(ttv.below, typ, tag)
+	}
+	return EnvEntry{}, false
+}
+
+func (e *mapEnv) layer(name string) EnvLayer {
+	return envLayer(name, maps.All(*e), func(tag Tag) (typeToVal, bool) {
+		ttv, ok := (*e)[tag]
+		return ttv, ok
+	})
+}
+
+// envLayer describes a layer that holds the values in all, which get looks
+// up by tag.
+func envLayer(name string, all iter.Seq2[Tag, typeToVal], get func(Tag) (typeToVal, bool)) EnvLayer {
+	return EnvLayer{
+		Name:    name,
+		Entries: layerEntries(all),
+		lookup: func(typ reflect.Type, tag Tag) (EnvEntry, bool) {
+			if ttv, ok := get(tag); ok {
+				return explainTypeToVal(ttv, typ, tag)
+			}
+			return EnvEntry{}, false
+		},
+	}
+}
+
+// layerEntries lists what's registered in a layer, sorted by tag and then in
+// order of precedence.
+func layerEntries(all iter.Seq2[Tag, typeToVal]) []EnvEntry {
+	byTag := make(map[Tag]typeToVal)
+	for tag, ttv := range all {
+		byTag[tag] = ttv
+	}
+	tags := slices.SortedFunc(maps.Keys(byTag), func(a, b Tag) int {
+		return cmp.Compare(tagString(a), tagString(b))
+	})
+	var entries []EnvEntry
+	for _, tag := range tags {
+		entries = appendEntries(entries, byTag[tag], tag)
+	}
+	return entries
+}
+
+func appendEntries(entries []EnvEntry, ttv typeToVal, tag Tag) []EnvEntry {
+	switch ttv := ttv.(type) {
+	case mapTypeToVal:
+		entries = appendTypeEntries(entries, maps.All(ttv), tag)
+	case trieTypeToVal:
+		entries = appendTypeEntries(entries, ttv.types.all(), tag)
+	case valForAllTypes:
+		entries = append(entries, EnvEntry{Tag: tag, Val: ttv.val})
+	case layeredTypeToVal:
+		entries = appendTypeEntries(entries, ttv.types.types.all(), tag)
+		entries = append(entries, EnvEntry{Tag: tag, Val: ttv.all.val})
+	case *ruleTypeToVal:
+		entries = appendTypeEntries(entries, maps.All(ttv.types), tag)
+		for _, rv := range ttv.rules {
+			entries = append(entries, EnvEntry{Tag: tag, Val: rv.val, Rule: rv.rule.desc})
+		}
+	case stackedTypeToVal:
+		entries = appendEntries(entries, ttv.top, tag)
+		entries = appendEntries(entries, ttv.below, tag)
+	}
+	return entries
+}
+
+// appendTypeEntries appends the values registered with Set for tag, sorted by
+// type.
+func appendTypeEntries(entries []EnvEntry, all iter.Seq2[reflect.Type, Val], tag Tag) []EnvEntry {
+	start := len(entries)
+	for typ, val := range all {
+		entries = append(entries, EnvEntry{Type: typ, Tag: tag, Val: val})
+	}
+	slices.SortFunc(entries[start:], func(a, b EnvEntry) int {
+		return cmp.Compare(typeName(a.Type), typeName(b.Type))
+	})
+	return entries
+}
+
+func (e *mapEnv) EnvLayers() []EnvLayer {
+	return []EnvLayer{e.layer("NewEnv")}
+}
+
+func (e *syncEnv) EnvLayers() []EnvLayer {
+	return []EnvLayer{e.load().layer("NewSyncEnv")}
+}
+
+func (e *wrappedEnv) EnvLayers() []EnvLayer {
+	return append([]EnvLayer{e.data.layer("WrapEnv")}, EnvLayers(e.parent)...)
+}
+
+func (e *frozenEnv) EnvLayers() []EnvLayer {
+	layers := []EnvLayer{envLayer("Freeze", e.data.all(), e.data.get)}
+	if e.rest != nil {
+		layers = append(layers, EnvLayers(e.rest)...)
+	}
+	return layers
+}
@@ -0,0 +1,342 @@
+package ops
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Doc describes how formatted text may be laid out, so that values can be
+// kept on one line when they fit and broken across lines when they don't.
+type Doc interface {
+	docIsAClosedType()
+}
+
+// DocFmt is implemented by Fmts that can describe the layout of a value.  Any
+// other Fmt is treated as producing a DocText.
+type DocFmt interface {
+	Fmt
+	FmtDoc(Env, reflect.Value) Doc
+}
+
+type docText string
+
+func (docText) docIsAClosedType() {}
+
+// DocText is literal text.  Any newlines in it are indented to the current
+// nesting level, and force enclosing groups to break.
+func DocText(s string) Doc {
+	return docText(s)
+}
+
+type docConcat []Doc
+
+func (docConcat) docIsAClosedType() {}
+
+func DocConcat(docs ...Doc) Doc {
+	return docConcat(docs)
+}
+
+type docGroup struct {
+	doc  Doc
+	flat bool
+}
+
+func (docGroup) docIsAClosedType() {}
+
+// DocGroup lays out d on a single line if it fits in the remaining width, and
+// otherwise breaks every DocLine directly inside it.
+func DocGroup(d Doc) Doc {
+	return docGroup{doc: d}
+}
+
+type docNest struct {
+	doc Doc
+}
+
+func (docNest) docIsAClosedType() {}
+
+// DocNest indents the lines that start inside d by one more level.
+func DocNest(d Doc) Doc {
+	return docNest{doc: d}
+}
+
+type docLine struct {
+	flat string
+}
+
+func (docLine) docIsAClosedType() {}
+
+// DocLine is a line break, or a space when its group fits on one line.
+func DocLine() Doc {
+	return docLine{flat: " "}
+}
+
+// DocSoftLine is a line break, or nothing when its group fits on one line.
+func DocSoftLine() Doc {
+	return docLine{}
+}
+
+type docIfBreak struct {
+	broken, flat Doc
+}
+
+func (docIfBreak) docIsAClosedType() {}
+
+// DocIfBreak is broken when its group is broken across lines, and flat
+// otherwise.
+func DocIfBreak(broken, flat Doc) Doc {
+	return docIfBreak{broken: broken, flat: flat}
+}
+
+// docFunc is rendered by calling f, which renders the rest of the Doc with
+// out as it produces it.  docFuncs are only used at the default width, where
+// no group fits on one line unless it has to, so the layout never depends on
+// what a docFunc will produce.
+type docFunc struct {
+	f func(out docOut)
+}
+
+func (docFunc) docIsAClosedType() {}
+
+// docOut renders in the place of a docFunc.
+type docOut struct {
+	r      *docRenderer
+	indent int
+	flat   bool
+}
+
+func (o docOut) emit(d Doc) {
+	o.r.renderItem(docItem{indent: o.indent, flat: o.flat, doc: d})
+}
+
+// Write renders p as text.
+func (o docOut) Write(p []byte) (int, error) {
+	o.r.text(o.indent, string(p))
+	if o.r.err != nil {
+		return 0, o.r.err
+	}
+	return len(p), nil
+}
+
+func (o docOut) fail(err error) {
+	if o.r.err == nil {
+		o.r.err = err
+	}
+}
+
+// fmtStream returns the Doc made up of the Docs that f emits.  At the default
+// width, f runs as the Doc is rendered, so that each part of a value is
+// written as soon as it's formatted.  Otherwise the layout depends on what
+// comes later, so f runs now.  Either way, the Doc must be rendered once.
+func fmtStream(env Env, f func(emit func(Doc))) Doc {
+	if fmtWidth(env) == 0 {
+		return docFunc{f: func(out docOut) { f(out.emit) }}
+	}
+	var docs []Doc
+	f(func(d Doc) { docs = append(docs, d) })
+	if len(docs) == 1 {
+		return docs[0]
+	}
+	return DocConcat(docs...)
+}
+
+// fmtDoc adapts impl, which may not know about Docs, to produce one.
+func fmtDoc(env Env, impl Fmt, v reflect.Value) Doc {
+	if df, ok := impl.(DocFmt); ok {
+		return df.FmtDoc(env, v)
+	}
+	if fw, ok := impl.(FmtWriter); ok && fmtWidth(env) == 0 {
+		return docFunc{f: func(out docOut) {
+			lw := newFmtLimitWriter(out, env)
+			if err := fw.FmtTo(lw, env, v); err != nil {
+				out.fail(err)
+			} else if err := lw.finish(); err != nil {
+				out.fail(err)
+			}
+		}}
+	}
+	return fmtLeaf(env, impl.Fmt(env, v))
+}
+
+type fmtWidthTag struct{}
+
+// FmtOptWidth makes Format keep composite values on one line when they fit in
+// width columns.  With the default width of 0, composite values are always
+// broken across lines.
+func FmtOptWidth(width int) Opt {
+	return OptFunc(func(env Env) {
+		env.SetAll(fmtWidthTag{}, width)
+	})
+}
+
+// RenderDoc lays out d in the width set with FmtOptWidth.  It's useful for
+// implementing the Fmt method of a DocFmt.
+func RenderDoc(env Env, d Doc) string {
+	var sb strings.Builder
+	// Writing to a strings.Builder can't fail.
+	_ = renderDocTo(&sb, env, d)
+	return sb.String()
+}
+
+func fmtWidth(env Env) int {
+	if env != nil {
+		if val, ok := env.Get(fmtType, fmtWidthTag{}); ok {
+			return val.(int)
+		}
+	}
+	return 0
+}
+
+func renderDocTo(w io.Writer, env Env, d Doc) error {
+	width := fmtWidth(env)
+	r := docRenderer{width: width, out: w, pending: -1, indentBlank: width == 0}
+	r.render(d)
+	return r.err
+}
+
+const docIndent = "  "
+
+type docItem struct {
+	indent int
+	flat   bool
+	doc    Doc
+}
+
+type docRenderer struct {
+	width int
+	out   io.Writer
+	// The first error from out, after which nothing more is written.
+	err error
+	col int
+	// The indentation to write before the next text, or -1 if there is none.
+	// Writing it lazily keeps blank lines free of trailing spaces.
+	pending int
+	// Set to indent blank lines anyway, as Format did before it supported
+	// widths.
+	indentBlank bool
+}
+
+func (r *docRenderer) render(d Doc) {
+	r.renderItem(docItem{doc: d})
+}
+
+func (r *docRenderer) renderItem(item docItem) {
+	stack := []docItem{item}
+	for len(stack) > 0 && r.err == nil {
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := item.doc.(type) {
+		case nil:
+			// Nothing to do.
+		case docText:
+			r.text(item.indent, string(d))
+		case docConcat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, docItem{indent: item.indent, flat: item.flat, doc: d[i]})
+			}
+		case docGroup:
+			flat := item.flat || d.flat
+			if !flat {
+				flat = fits(r.width-r.col, docItem{indent: item.indent, flat: true, doc: d.doc}, stack)
+			}
+			stack = append(stack, docItem{indent: item.indent, flat: flat, doc: d.doc})
+		case docNest:
+			stack = append(stack, docItem{indent: item.indent + 1, flat: item.flat, doc: d.doc})
+		case docLine:
+			if item.flat {
+				r.text(item.indent, d.flat)
+			} else {
+				r.newline(item.indent)
+			}
+		case docIfBreak:
+			next := d.broken
+			if item.flat {
+				next = d.flat
+			}
+			stack = append(stack, docItem{indent: item.indent, flat: item.flat, doc: next})
+		case docFunc:
+			d.f(docOut{r: r, indent: item.indent, flat: item.flat})
+		}
+	}
+}
+
+func (r *docRenderer) text(indent int, s string) {
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			r.newline(indent)
+		}
+		if line == "" && (i == 0 || !r.indentBlank) {
+			continue
+		}
+		if r.pending >= 0 {
+			r.write(strings.Repeat(docIndent, r.pending))
+			r.pending = -1
+		}
+		r.write(line)
+		r.col += utf8.RuneCountInString(line)
+	}
+}
+
+func (r *docRenderer) write(s string) {
+	if r.err == nil {
+		_, r.err = io.WriteString(r.out, s)
+	}
+}
+
+func (r *docRenderer) newline(indent int) {
+	r.write("\n")
+	r.pending = indent
+	r.col = indent * len(docIndent)
+}
+
+// fits reports whether group, laid out flat, fits in width along with
+// whatever follows it in rest up to the next line break.
+func fits(width int, group docItem, rest []docItem) bool {
+	stack := []docItem{group}
+	inGroup := true
+	for width >= 0 {
+		if len(stack) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			inGroup = false
+			stack = append(stack, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		item := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch d := item.doc.(type) {
+		case docText:
+			line, _, multiline := strings.Cut(string(d), "\n")
+			width -= utf8.RuneCountInString(line)
+			if multiline {
+				return !inGroup && width >= 0
+			}
+		case docConcat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, docItem{flat: item.flat, doc: d[i]})
+			}
+		case docGroup:
+			stack = append(stack, docItem{flat: item.flat || inGroup || d.flat, doc: d.doc})
+		case docNest:
+			stack = append(stack, docItem{flat: item.flat, doc: d.doc})
+		case docLine:
+			if !item.flat {
+				return true
+			}
+			width -= utf8.RuneCountInString(d.flat)
+		case docIfBreak:
+			next := d.broken
+			if item.flat {
+				next = d.flat
+			}
+			stack = append(stack, docItem{flat: item.flat, doc: next})
+		case docFunc:
+			// What it will produce isn't known yet.
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package ops_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+type pair struct {
+	A, B any
+}
+
+// fmtPair lays out a pair like a function call.
+type fmtPair struct{}
+
+func (f fmtPair) Fmt(env ops.Env, v reflect.Value) string {
+	return ops.RenderDoc(env, f.FmtDoc(env, v))
+}
+
+func (fmtPair) FmtDoc(env ops.Env, v reflect.Value) ops.Doc {
+	return ops.DocGroup(ops.DocConcat(
+		ops.DocText("pair("),
+		ops.DocNest(ops.DocConcat(
+			ops.DocSoftLine(),
+			ops.FmtDeep{}.FmtDoc(env, v.Field(0)),
+			ops.DocText(","),
+			ops.DocLine(),
+			ops.FmtDeep{}.FmtDoc(env, v.Field(1)),
+		)),
+		ops.DocSoftLine(),
+		ops.DocText(")"),
+	))
+}
+
+func TestFormatWidth(t *testing.T) {
+	type Point struct {
+		X, Y int
+	}
+	type Shape struct {
+		Name   string
+		Points []Point
+	}
+	shape := Shape{Name: "triangle", Points: []Point{{0, 0}, {1, 0}, {0, 1}}}
+
+	tests := []struct {
+		name  string
+		width int
+		opt   ops.Opt
+		v     any
+		want  string
+	}{
+		{
+			name:  "Fits",
+			width: 200,
+			v:     shape,
+			want:  `ops_test.Shape{Name: "triangle", Points: []ops_test.Point{ops_test.Point{X: 0, Y: 0}, ops_test.Point{X: 1, Y: 0}, ops_test.Point{X: 0, Y: 1}}}`,
+		},
+		{
+			name:  "Inner Parts Fit",
+			width: 60,
+			v:     shape,
+			want: `ops_test.Shape{
+  Name: "triangle",
+  Points: []ops_test.Point{
+    ops_test.Point{X: 0, Y: 0},
+    ops_test.Point{X: 1, Y: 0},
+    ops_test.Point{X: 0, Y: 1},
+  },
+}`,
+		},
+		{
+			name:  "Closing Text Counts",
+			width: 13,
+			v:     []int{1, 2, 3},
+			want: `[]int{
+  1,
+  2,
+  3,
+}`,
+		},
+		{
+			name:  "Exact Fit",
+			width: 14,
+			v:     []int{1, 2, 3},
+			want:  `[]int{1, 2, 3}`,
+		},
+		{
+			name: "Empty",
+			v:    []int{},
+			want: "[]int{\n\n}",
+		},
+		{
+			name:  "Empty With Width",
+			width: 80,
+			v:     []int{},
+			want:  `[]int{}`,
+		},
+		{
+			name:  "Multi-line FmtFunc",
+			width: 80,
+			opt: ops.FmtOpt(reflect.TypeFor[string](), ops.FmtFunc(func(_ ops.Env, v reflect.Value) string {
+				return strings.ReplaceAll(v.String(), " ", "\n")
+			})),
+			v: []string{"a b"},
+			want: `[]string{
+  a
+  b,
+}`,
+		},
+		{
+			name:  "DocFmt",
+			width: 30,
+			opt:   ops.FmtOpt(reflect.TypeFor[pair](), fmtPair{}),
+			v:     []pair{{1, "x"}, {[]int{1, 2, 3, 4, 5}, 2}},
+			want: `[]ops_test.pair{
+  pair(any(1), any("x")),
+  pair(
+    any([]int{1, 2, 3, 4, 5}),
+    any(2)
+  ),
+}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := ops.Opts{ops.FmtOptWidth(tt.width)}
+			if tt.opt != nil {
+				opts = append(opts, tt.opt)
+			}
+			env := ops.WrapEnv(ops.NewEnv(), opts...)
+			if got := ops.FormatVal(env, reflect.ValueOf(tt.v)); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
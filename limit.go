@@ -0,0 +1,271 @@
+package ops
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"unicode/utf8"
+)
+
+// FmtLimits bounds how much of a value is formatted, so that huge values can
+// be logged safely.  Zero fields impose no limit.
+type FmtLimits struct {
+	// Composite values nested more than Depth levels deep are shown as T{...}.
+	Depth int
+	// At most Elems elements of each slice, array, or map are shown.
+	Elems int
+	// At most String bytes of each string are shown.
+	String int
+	// Once about Bytes bytes of output have been produced, the remaining
+	// elements and fields of composite values are left out, and so is the rest
+	// of any string or other value that would go past the limit.  Output is
+	// measured as if it were laid out on one line.
+	Bytes int
+}
+
+// merge returns l with any non-zero fields of override replacing its own.
+func (l FmtLimits) merge(override FmtLimits) FmtLimits {
+	if override.Depth != 0 {
+		l.Depth = override.Depth
+	}
+	if override.Elems != 0 {
+		l.Elems = override.Elems
+	}
+	if override.String != 0 {
+		l.String = override.String
+	}
+	if override.Bytes != 0 {
+		l.Bytes = override.Bytes
+	}
+	return l
+}
+
+type fmtLimitsTag struct{}
+
+func FmtOptLimits(limits FmtLimits) Opt {
+	return OptFunc(func(env Env) {
+		env.SetAll(fmtLimitsTag{}, limits)
+	})
+}
+
+// fmtLimitFrame tracks the progress of formatting against limits.  A
+// composite value with its own limits gets its own frame, with Depth and Bytes
+// measured from that value if it overrides them.
+type fmtLimitFrame struct {
+	limits FmtLimits
+	depth  int
+	bytes  int
+}
+
+func (f *fmtLimitFrame) exhausted() bool {
+	return f.limits.Bytes > 0 && f.bytes >= f.limits.Bytes
+}
+
+// full reports whether no more elements should be shown after the first n.
+func (f *fmtLimitFrame) full(n int) bool {
+	return (f.limits.Elems > 0 && n >= f.limits.Elems) || f.exhausted()
+}
+
+func envFmtLimits(env Env) FmtLimits {
+	if env != nil {
+		if val, ok := env.Get(fmtType, fmtLimitsTag{}); ok {
+			return val.(FmtLimits)
+		}
+	}
+	return FmtLimits{}
+}
+
+// enterFmtLimits is called as a composite value with its own limits override
+// starts to be formatted.  It returns false if the value is nested too deeply
+// to be shown.  Either way, leave must be called once the value is done.
+func enterFmtLimits(env Env, s *opState, override FmtLimits) (frame *fmtLimitFrame, ok bool, leave func()) {
+	outer := s.fmtLimits
+	frame = outer
+	if outer == nil {
+		frame = &fmtLimitFrame{limits: envFmtLimits(env).merge(override)}
+	} else if override != (FmtLimits{}) {
+		frame = &fmtLimitFrame{limits: outer.limits.merge(override)}
+		if override.Depth == 0 {
+			frame.depth = outer.depth
+		}
+		if override.Bytes == 0 {
+			frame.bytes = outer.bytes
+		}
+	}
+	s.fmtLimits = frame
+	ok = frame.limits.Depth <= 0 || frame.depth < frame.limits.Depth
+	frame.depth++
+	return frame, ok, func() {
+		frame.depth--
+		if frame == outer {
+			return
+		}
+		s.fmtLimits = outer
+		if outer == nil {
+			return
+		}
+		if frame.limits.Bytes == outer.limits.Bytes {
+			outer.bytes = frame.bytes
+		} else {
+			outer.bytes += frame.bytes
+		}
+	}
+}
+
+// fmtRemaining returns how many more bytes of output the Bytes limit allows,
+// or -1 if there's no limit.
+func fmtRemaining(env Env) int {
+	if env != nil {
+		if val, ok := env.Get(stateType, stateTag{}); ok {
+			if frame := val.(*opState).fmtLimits; frame != nil {
+				if frame.limits.Bytes <= 0 {
+					return -1
+				}
+				return max(frame.limits.Bytes-frame.bytes, 0)
+			}
+		}
+	}
+	// Nothing has been produced outside of a composite value.
+	if limit := envFmtLimits(env).Bytes; limit > 0 {
+		return limit
+	}
+	return -1
+}
+
+// runeCut moves n back to the start of the rune it's in the middle of, if
+// any.
+func runeCut[S ~string | ~[]byte](s S, n int) int {
+	for n > 0 && n < len(s) && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return n
+}
+
+// fmtCut returns how much of s fits in what's left of the Bytes limit, or
+// len(s) if all of it does or if cutting it wouldn't make the output shorter.
+func fmtCut(env Env, s string) int {
+	rem := fmtRemaining(env)
+	if rem < 0 || len(s) <= rem {
+		return len(s)
+	}
+	cut := runeCut(s, rem)
+	if cut+len(moreString(len(s)-cut)) >= len(s) {
+		return len(s)
+	}
+	return cut
+}
+
+// currentFmtLimits returns the limits that apply to a value that doesn't have
+// its own.
+func currentFmtLimits(env Env) FmtLimits {
+	if env != nil {
+		if val, ok := env.Get(stateType, stateTag{}); ok {
+			if frame := val.(*opState).fmtLimits; frame != nil {
+				return frame.limits
+			}
+		}
+	}
+	return envFmtLimits(env)
+}
+
+// countFmtBytes records that n bytes of output were produced.
+func countFmtBytes(env Env, n int) {
+	if env == nil {
+		return
+	}
+	if val, ok := env.Get(stateType, stateTag{}); ok {
+		if frame := val.(*opState).fmtLimits; frame != nil {
+			frame.bytes += n
+		}
+	}
+}
+
+// fmtSepBytes is the length of the separator before the nth part of a
+// composite value when it's laid out on one line.
+func fmtSepBytes(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return len(", ")
+}
+
+// fmtLeaf is the Doc for a value that isn't broken down any further.  Any of
+// it past the Bytes limit is left out.
+func fmtLeaf(env Env, s string) Doc {
+	if cut := fmtCut(env, s); cut < len(s) {
+		s = s[:cut] + moreString(len(s)-cut)
+	}
+	return fmtText(env, s)
+}
+
+// fmtText is like fmtLeaf, for text that has already been limited.
+func fmtText(env Env, s string) Doc {
+	countFmtBytes(env, len(s))
+	return DocText(s)
+}
+
+// fmtLimitWriter is used by a FmtWriter to write a value.  It leaves out
+// whatever goes past the Bytes limit, and counts the rest towards it.
+type fmtLimitWriter struct {
+	w   io.Writer
+	env Env
+	// What's left of the limit, or -1 if there's no limit.
+	budget  int
+	dropped int
+}
+
+func newFmtLimitWriter(w io.Writer, env Env) *fmtLimitWriter {
+	return &fmtLimitWriter{w: w, env: env, budget: fmtRemaining(env)}
+}
+
+func (lw *fmtLimitWriter) Write(p []byte) (int, error) {
+	keep := len(p)
+	if lw.budget >= 0 && lw.budget < keep {
+		keep = runeCut(p, lw.budget)
+	}
+	if lw.dropped > 0 {
+		keep = 0
+	}
+	if keep > 0 {
+		if _, err := lw.w.Write(p[:keep]); err != nil {
+			return 0, err
+		}
+		countFmtBytes(lw.env, keep)
+		if lw.budget >= 0 {
+			lw.budget -= keep
+		}
+	}
+	lw.dropped += len(p) - keep
+	return len(p), nil
+}
+
+// finish notes how much was left out, if anything.
+func (lw *fmtLimitWriter) finish() error {
+	if lw.dropped == 0 {
+		return nil
+	}
+	more := moreString(lw.dropped)
+	countFmtBytes(lw.env, len(more))
+	_, err := io.WriteString(lw.w, more)
+	return err
+}
+
+func moreString(n int) string {
+	return fmt.Sprintf("... (%d more)", n)
+}
+
+func fmtStringLimited(env Env, v reflect.Value) string {
+	s := v.String()
+	cut := fmtCut(env, s)
+	if limit := currentFmtLimits(env).String; limit > 0 && limit < cut {
+		cut = runeCut(s, limit)
+	}
+	if cut == len(s) {
+		return literalString(v, reflect.Value.String)
+	}
+	str := fmt.Sprintf("%q%s", s[:cut], moreString(len(s)-cut))
+	if _, ok := directTypes[v.Type()]; ok {
+		return str
+	}
+	return fmt.Sprintf("%s(%s)", typeName(v.Type()), str)
+}
@@ -0,0 +1,88 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var (
+	boolType       = reflect.TypeFor[bool]()
+	intType        = reflect.TypeFor[int]()
+	formatterType  = reflect.TypeFor[fmt.Formatter]()
+	goStringerType = reflect.TypeFor[fmt.GoStringer]()
+)
+
+// binaryMethod looks for a method like Equal(T) bool or Compare(T) int on the
+// non-interface type t, returning its func value with the receiver as the
+// first argument.  The method may have a pointer receiver.
+func binaryMethod(t reflect.Type, name string, out reflect.Type) (reflect.Method, bool, bool) {
+	if t.Kind() == reflect.Interface {
+		return reflect.Method{}, false, false
+	}
+	for _, recv := range []reflect.Type{t, reflect.PointerTo(t)} {
+		m, ok := recv.MethodByName(name)
+		if !ok {
+			continue
+		}
+		mt := m.Type
+		if mt.NumIn() == 2 && mt.In(1) == t && mt.NumOut() == 1 && mt.Out(0) == out {
+			return m, recv != t, true
+		}
+	}
+	return reflect.Method{}, false, false
+}
+
+// receiver returns v, or a pointer to v (or to a copy of it) if ptr is set.
+func receiver(v reflect.Value, ptr bool) reflect.Value {
+	if !ptr {
+		return v
+	}
+	if v.CanAddr() {
+		return v.Addr()
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p
+}
+
+func callBinaryMethod(name string, out reflect.Type, v1, v2 reflect.Value) reflect.Value {
+	m, ptr, ok := binaryMethod(v1.Type(), name, out)
+	if !ok {
+		panic(fmt.Errorf("%w: %s has no method %s(%s) %s", ErrWrongType, typeName(v1.Type()), name, typeName(v1.Type()), out))
+	}
+	if !v1.CanInterface() || !v2.CanInterface() {
+		panic(ErrInvalid)
+	}
+	return m.Func.Call([]reflect.Value{receiver(v1, ptr), v2})[0]
+}
+
+// implementsIface reports whether t or *t implements iface, and whether the
+// pointer is needed.
+func implementsIface(t, iface reflect.Type) (bool, bool) {
+	switch {
+	case t.Kind() == reflect.Interface:
+		return false, false
+	case t.Implements(iface):
+		return false, true
+	case reflect.PointerTo(t).Implements(iface):
+		return true, true
+	default:
+		return false, false
+	}
+}
+
+// ifaceFor returns v as an instance of iface, panicking if it isn't one.
+func ifaceFor(v reflect.Value, iface reflect.Type) any {
+	ptr, ok := implementsIface(v.Type(), iface)
+	if !ok {
+		panic(fmt.Errorf("%w: %s does not implement %s", ErrWrongType, typeName(v.Type()), iface))
+	}
+	if !v.CanInterface() {
+		panic(ErrInvalid)
+	}
+	return receiver(v, ptr).Interface()
+}
+
+type eqMethodsTag struct{}
+type ordMethodsTag struct{}
+type fmtMethodsTag struct{}
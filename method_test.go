@@ -0,0 +1,87 @@
+package ops_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-ops"
+)
+
+type caseless string
+
+func (c *caseless) Equal(other caseless) bool {
+	return len(*c) == len(other) // Good enough for testing.
+}
+
+type version struct {
+	Major, Minor int
+}
+
+func (v version) GoString() string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+type celsius float64
+
+func (c celsius) Format(f fmt.State, verb rune) {
+	fmt.Fprintf(f, "%.1f°C", float64(c))
+}
+
+func TestMethods(t *testing.T) {
+	utc := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	local := utc.In(time.FixedZone("X", 3600))
+
+	t.Run("Eq", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOptMethods())
+		if !ops.Equal(env, utc, local) {
+			t.Errorf("times are not equal:\n%s", ops.Diff(env, utc, local))
+		}
+		if ops.Equal(env, utc, utc.Add(time.Second)) {
+			t.Error("different times are equal")
+		}
+		if ops.Diff(env, utc, local) != nil {
+			t.Error("got a diff for equal times")
+		}
+		if ops.Hash(env, utc) != ops.Hash(env, local) {
+			t.Error("equal times have different hashes")
+		}
+		if !ops.Equal(env, map[caseless]int{"ab": 1}, map[caseless]int{"xy": 1}) {
+			t.Error("pointer receiver Equal method was not used for map keys")
+		}
+	})
+
+	t.Run("Ord", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.OrdOptMethods())
+		if got := ops.Order(env, utc, local); got != 0 {
+			t.Errorf("got %d, want 0", got)
+		}
+		if got := ops.Order(env, []time.Time{utc}, []time.Time{utc.Add(time.Second)}); got != -1 {
+			t.Errorf("got %d, want -1", got)
+		}
+	})
+
+	t.Run("Fmt", func(t *testing.T) {
+		type Reading struct {
+			Version version
+			Temp    celsius
+		}
+		env := ops.WrapEnv(ops.NewEnv(), ops.FmtOptMethods())
+		want := `ops_test.Reading{
+  Version: v1.2,
+  Temp: 21.5°C,
+}`
+		if got := ops.Format(env, Reading{Version: version{1, 2}, Temp: 21.5}); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Missing Method", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[int](), ops.EqMethod{}))
+		if _, err := ops.TryEqual(env, 1, 2); !errors.Is(err, ops.ErrWrongType) {
+			t.Errorf("got error %v, want %v", err, ops.ErrWrongType)
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package ops
 
+import "reflect"
+
 type Opt interface {
 	Update(Env)
 }
@@ -16,4 +18,19 @@ func (opts Opts) Update(env Env) {
 	for _, opt := range opts {
 		opt.Update(env)
 	}
-}
\ No newline at end of file
+}
+
+// Some options just turn on a behavior, by setting tag to true for every type.
+func flagOpt(tag Tag) Opt {
+	return OptFunc(func(env Env) {
+		env.SetAll(tag, true)
+	})
+}
+
+func flagSet(env Env, t reflect.Type, tag Tag) bool {
+	if env == nil {
+		return false
+	}
+	val, ok := env.Get(t, tag)
+	return ok && val == true
+}
@@ -3,6 +3,7 @@ package ops
 import (
 	"cmp"
 	"reflect"
+	"strings"
 )
 
 type Ord interface {
@@ -12,7 +13,7 @@ type Ord interface {
 type ordTag struct{}
 
 func Order[T any](env Env, a, b T) int {
-	return OrderVals(env, reflect.ValueOf(a), reflect.ValueOf(b))
+	return OrderVals(env, ValueFor(a), ValueFor(b))
 }
 
 func OrderVals(env Env, a, b reflect.Value) int {
@@ -40,6 +41,28 @@ func OrderVals(env Env, a, b reflect.Value) int {
 	return impl.Ord(env, a, b)
 }
 
+func TryOrderVals(env Env, a, b reflect.Value) (int, error) {
+	var result int
+	err := try(func() {
+		result = OrderVals(env, a, b)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func TryOrder[T any](env Env, a, b T) (int, error) {
+	var result int
+	err := try(func() {
+		result = Order(env, a, b)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
 type ordDefault struct{}
 
 func orderLiteralCan[T cmp.Ordered](v1, v2 reflect.Value, can func(reflect.Value) bool, f func(reflect.Value) T) int {
@@ -57,10 +80,17 @@ func orderLiteral[T cmp.Ordered](v1, v2 reflect.Value, f func(reflect.Value) T)
 
 func (o ordDefault) Ord(env Env, v1, v2 reflect.Value) int {
 	t := v1.Type()
+	if flagSet(env, t, ordMethodsTag{}) && v1.CanInterface() && v2.CanInterface() {
+		if _, _, ok := binaryMethod(t, "Compare", intType); ok {
+			return OrdMethod{}.Ord(env, v1, v2)
+		}
+	}
 	switch t.Kind() {
-	case reflect.Struct, // TODO: implement.
-		reflect.Map,                  // TODO: maybe implement.
-		reflect.Slice, reflect.Array, // TODO: implement.
+	case reflect.Struct:
+		return OrdStruct{}.Ord(env, v1, v2)
+	case reflect.Slice, reflect.Array:
+		return OrdSlice{}.Ord(env, v1, v2)
+	case reflect.Map, // TODO: maybe implement.
 		reflect.Chan, reflect.Func, reflect.UnsafePointer,
 		reflect.Bool, // TODO: maybe implement.
 		reflect.Uintptr,
@@ -75,9 +105,9 @@ func (o ordDefault) Ord(env Env, v1, v2 reflect.Value) int {
 	case reflect.String:
 		return orderLiteral(v1, v2, reflect.Value.String)
 	case reflect.Pointer:
-		return 0 // TODO: implement
+		return OrdPointer{}.Ord(env, v1, v2)
 	case reflect.Interface:
-		return 0 // TODO: implement
+		return OrdInterface{}.Ord(env, v1, v2)
 	default:
 		panic(ErrWrongType) // TODO: better error?
 	}
@@ -89,6 +119,208 @@ func (o OrdDeep) Ord(env Env, v1, v2 reflect.Value) int {
 	return OrderVals(env, v1, v2)
 }
 
+type OrdFunc func(Env, reflect.Value, reflect.Value) int
+
+func (f OrdFunc) Ord(env Env, v1, v2 reflect.Value) int {
+	return f(env, v1, v2)
+}
+
+// OrdMethod orders values of type T with their Compare(T) int method, which
+// may have a pointer receiver.
+type OrdMethod struct{}
+
+func (OrdMethod) Ord(_ Env, v1, v2 reflect.Value) int {
+	return int(callBinaryMethod("Compare", intType, v1, v2).Int())
+}
+
+// OrdNone treats all values as equal.
+type OrdNone struct{}
+
+func (OrdNone) Ord(Env, reflect.Value, reflect.Value) int {
+	return 0
+}
+
+// OrdReverse inverts the order of Inner, which defaults to OrdDeep.
+type OrdReverse struct {
+	Inner Ord
+}
+
+func (or OrdReverse) Ord(env Env, v1, v2 reflect.Value) int {
+	impl := or.Inner
+	if impl == nil {
+		impl = OrdDeep{}
+	}
+	return impl.Ord(env, v2, v1)
+}
+
+type OrdStruct struct {
+	Fields map[Field]Ord
+	// Unexported fields are compared only if this is set or they have an entry
+	// in Fields or an "ops" struct tag.  Otherwise, ordering a struct with
+	// unexported fields panics with ErrWrongType, since they're often what
+	// tells its values apart, as with time.Time.
+	IncludeUnexported bool
+}
+
+func (os OrdStruct) Ord(env Env, v1, v2 reflect.Value) int {
+	t := v1.Type()
+	if t.Kind() != reflect.Struct {
+		panic(ErrWrongType)
+	}
+	for f := range os.Fields {
+		if f == nil {
+			panic(ErrNilField)
+		}
+	}
+	tags := tagsFor(t)
+	env, paths := enterPaths(env, ordPathTag{})
+	defer paths.leave()
+	var fieldName string
+	defer annotateField(t, &fieldName)
+	override := func(fNum int) (Ord, bool) {
+		impl, ok := os.Fields[fieldKey(t.Field(fNum))]
+		if !ok && tags != nil && tags[fNum].ord != nil {
+			impl, ok = tags[fNum].ord, true
+		}
+		return impl, ok
+	}
+	// Checked up front, so that whether this panics doesn't depend on the
+	// values.
+	if !os.IncludeUnexported {
+		for fNum := range t.NumField() {
+			f := t.Field(fNum)
+			if _, ok := override(fNum); !f.IsExported() && !ok {
+				fieldName = f.Name
+				panic(ErrWrongType)
+			}
+		}
+	}
+	for fNum := range t.NumField() {
+		fieldName = t.Field(fNum).Name
+		impl, _ := override(fNum)
+		if impl == nil {
+			impl = OrdDeep{}
+		}
+		impl = pathChild(paths, fieldPath(fieldName), impl)
+		if c := impl.Ord(env, v1.Field(fNum), v2.Field(fNum)); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+type OrdSlice struct {
+	Elems Ord
+}
+
+func (os OrdSlice) Ord(env Env, v1, v2 reflect.Value) int {
+	switch v1.Kind() {
+	case reflect.Slice, reflect.Array:
+		// ok
+	default:
+		panic(ErrWrongType)
+	}
+	env, s := withState(env)
+	if _, ok := s.ord.enter(v1, v2); !ok {
+		return 0
+	}
+	defer s.ord.leave(v1, v2)
+	elems := os.Elems
+	if elems == nil {
+		elems = OrdDeep{}
+	}
+	env, paths := enterPaths(env, ordPathTag{})
+	defer paths.leave()
+	idx := -1
+	defer annotateIndex(v1.Type(), &idx)
+	for elemNum := range min(v1.Len(), v2.Len()) {
+		idx = elemNum
+		impl := pathChild(paths, indexPath(elemNum), elems)
+		if c := impl.Ord(env, v1.Index(elemNum), v2.Index(elemNum)); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(v1.Len(), v2.Len())
+}
+
+func orderNil(nil1, nil2, nilFirst bool) int {
+	switch {
+	case nil1 == nil2:
+		return 0
+	case nil1 == nilFirst:
+		return -1
+	default:
+		return 1
+	}
+}
+
+type OrdPointer struct {
+	Elem     Ord
+	NilFirst bool
+}
+
+func (op OrdPointer) Ord(env Env, v1, v2 reflect.Value) int {
+	if v1.Kind() != reflect.Pointer {
+		panic(ErrWrongType)
+	}
+	if v1.IsNil() || v2.IsNil() {
+		return orderNil(v1.IsNil(), v2.IsNil(), op.NilFirst)
+	}
+	env, s := withState(env)
+	if _, ok := s.ord.enter(v1, v2); !ok {
+		return 0
+	}
+	defer s.ord.leave(v1, v2)
+	elem := op.Elem
+	if elem == nil {
+		elem = OrdDeep{}
+	}
+	return elem.Ord(env, v1.Elem(), v2.Elem())
+}
+
+type OrdInterface struct {
+	Elem      Ord
+	NilFirst  bool
+	TypeOrder func(reflect.Type, reflect.Type) int
+}
+
+// orderTypes orders types by name.  Distinct types can share a name, like
+// types named T declared in different functions, so ties are broken by the
+// address of the type's runtime descriptor, which is fixed for a given binary.
+func orderTypes(t1, t2 reflect.Type) int {
+	return cmp.Or(
+		strings.Compare(t1.String(), t2.String()),
+		strings.Compare(t1.PkgPath(), t2.PkgPath()),
+		cmp.Compare(reflect.ValueOf(t1).Pointer(), reflect.ValueOf(t2).Pointer()),
+	)
+}
+
+func (oi OrdInterface) Ord(env Env, v1, v2 reflect.Value) int {
+	if v1.Kind() != reflect.Interface {
+		panic(ErrWrongType)
+	}
+	if v1.IsNil() || v2.IsNil() {
+		return orderNil(v1.IsNil(), v2.IsNil(), oi.NilFirst)
+	}
+	e1 := v1.Elem()
+	e2 := v2.Elem()
+	if e1.Type() != e2.Type() {
+		typeOrder := oi.TypeOrder
+		if typeOrder == nil {
+			typeOrder = orderTypes
+		}
+		if c := typeOrder(e1.Type(), e2.Type()); c != 0 {
+			return c
+		}
+		panic(ErrWrongType)
+	}
+	elem := oi.Elem
+	if elem == nil {
+		elem = OrdDeep{}
+	}
+	return elem.Ord(env, e1, e2)
+}
+
 func OrdOpt(t reflect.Type, ord Ord) Opt {
 	return OptFunc(func(e Env) {
 		e.Set(t, ordTag{}, ord)
@@ -100,3 +332,9 @@ func OrdOptAll(ord Ord) Opt {
 		e.SetAll(ordTag{}, ord)
 	})
 }
+
+// OrdOptMethods makes the default Ord use OrdMethod for any type with a
+// Compare(T) int method.
+func OrdOptMethods() Opt {
+	return flagOpt(ordMethodsTag{})
+}
@@ -0,0 +1,281 @@
+package ops_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/krelinga/go-ops"
+)
+
+type orderKey struct {
+	Name  string
+	Rank  int
+	Parts []int
+}
+
+type orderSecret struct {
+	Name string
+	rank int
+}
+
+func TestOrder(t *testing.T) {
+	reverse := ops.OrdOpt(reflect.TypeFor[orderKey](), ops.OrdStruct{
+		Fields: map[ops.Field]ops.Ord{
+			ops.NamedField("Rank"): ops.OrdFunc(func(env ops.Env, v1, v2 reflect.Value) int {
+				return ops.OrderVals(env, v2, v1)
+			}),
+		},
+	})
+	tests := []struct {
+		name string
+		opt  ops.Opt
+		f    func(ops.Env) int
+		want int
+	}{
+		{
+			name: "Struct First Field Decides",
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderKey{Name: "a", Rank: 2}, orderKey{Name: "b", Rank: 1})
+			},
+			want: -1,
+		},
+		{
+			name: "Struct Later Field Decides",
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderKey{Name: "a", Rank: 2}, orderKey{Name: "a", Rank: 1})
+			},
+			want: 1,
+		},
+		{
+			name: "Struct Equal",
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderKey{Name: "a", Parts: []int{1}}, orderKey{Name: "a", Parts: []int{1}})
+			},
+			want: 0,
+		},
+		{
+			name: "Struct Field Override",
+			opt:  reverse,
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderKey{Name: "a", Rank: 2}, orderKey{Name: "a", Rank: 1})
+			},
+			want: -1,
+		},
+		{
+			name: "Slice Element Decides",
+			f: func(env ops.Env) int {
+				return ops.Order(env, []int{1, 3}, []int{2})
+			},
+			want: -1,
+		},
+		{
+			name: "Slice Prefix Is Smaller",
+			f: func(env ops.Env) int {
+				return ops.Order(env, []int{1, 2, 3}, []int{1, 2})
+			},
+			want: 1,
+		},
+		{
+			name: "Array",
+			f: func(env ops.Env) int {
+				return ops.Order(env, [2]string{"a", "b"}, [2]string{"a", "c"})
+			},
+			want: -1,
+		},
+		{
+			name: "OrdSlice Override",
+			opt: ops.OrdOpt(reflect.TypeFor[[]int](), ops.OrdSlice{
+				Elems: ops.OrdFunc(func(_ ops.Env, v1, v2 reflect.Value) int {
+					return int(v2.Int() - v1.Int())
+				}),
+			}),
+			f: func(env ops.Env) int {
+				return ops.Order(env, []int{1}, []int{2})
+			},
+			want: 1,
+		},
+		{
+			name: "Nil Pointer Last By Default",
+			f: func(env ops.Env) int {
+				one := 1
+				return ops.Order(env, nil, &one)
+			},
+			want: 1,
+		},
+		{
+			name: "Struct Include Unexported",
+			opt:  ops.OrdOpt(reflect.TypeFor[orderSecret](), ops.OrdStruct{IncludeUnexported: true}),
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderSecret{Name: "a", rank: 2}, orderSecret{Name: "a", rank: 1})
+			},
+			want: 1,
+		},
+		{
+			name: "Struct Unexported Field Override",
+			opt: ops.OrdOpt(reflect.TypeFor[orderSecret](), ops.OrdStruct{
+				Fields: map[ops.Field]ops.Ord{
+					ops.NamedField("rank"): ops.OrdReverse{},
+				},
+			}),
+			f: func(env ops.Env) int {
+				return ops.Order(env, orderSecret{Name: "a", rank: 2}, orderSecret{Name: "a", rank: 1})
+			},
+			want: -1,
+		},
+		{
+			name: "Nil Pointer First",
+			opt:  ops.OrdOpt(reflect.TypeFor[*int](), ops.OrdPointer{NilFirst: true}),
+			f: func(env ops.Env) int {
+				one := 1
+				return ops.Order(env, nil, &one)
+			},
+			want: -1,
+		},
+		{
+			name: "Pointer By Pointee",
+			f: func(env ops.Env) int {
+				one, two := 1, 2
+				return ops.Order(env, &two, &one)
+			},
+			want: 1,
+		},
+		{
+			name: "Interface Same Dynamic Type",
+			f: func(env ops.Env) int {
+				return ops.Order[any](env, "a", "b")
+			},
+			want: -1,
+		},
+		{
+			name: "Interface Different Dynamic Types",
+			f: func(env ops.Env) int {
+				return ops.Order[any](env, "a", 1)
+			},
+			want: 1,
+		},
+		{
+			name: "Interface Custom Type Order",
+			opt: ops.OrdOpt(reflect.TypeFor[any](), ops.OrdInterface{
+				TypeOrder: func(t1, t2 reflect.Type) int {
+					if t1.Kind() == reflect.String {
+						return -1
+					}
+					return 1
+				},
+			}),
+			f: func(env ops.Env) int {
+				return ops.Order[any](env, "a", 1)
+			},
+			want: -1,
+		},
+		{
+			name: "Interface Nil",
+			f: func(env ops.Env) int {
+				return ops.Order[any](env, nil, nil)
+			},
+			want: 0,
+		},
+		{
+			name: "Cycle",
+			f: func(env ops.Env) int {
+				return ops.Order(env, newRing(1, 2, 3), newRing(1, 2, 3))
+			},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := ops.NewEnv()
+			if tt.opt != nil {
+				env = ops.WrapEnv(env, tt.opt)
+			}
+			if got := tt.f(env); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTryOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       func() (int, error)
+		want    int
+		wantErr error
+	}{
+		{
+			name: "Orderable",
+			f: func() (int, error) {
+				return ops.TryOrder(nil, 1, 2)
+			},
+			want: -1,
+		},
+		{
+			name: "Unorderable Kind",
+			f: func() (int, error) {
+				return ops.TryOrder(nil, true, false)
+			},
+			wantErr: ops.ErrWrongType,
+		},
+		{
+			name: "Unexported Fields",
+			f: func() (int, error) {
+				return ops.TryOrder(nil, orderSecret{Name: "a"}, orderSecret{Name: "b"})
+			},
+			wantErr: ops.ErrWrongType,
+		},
+		{
+			name: "Unexported Fields Of Time",
+			f: func() (int, error) {
+				t := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+				return ops.TryOrder(nil, t, t.Add(time.Hour))
+			},
+			wantErr: ops.ErrWrongType,
+		},
+		{
+			name: "Invalid Values",
+			f: func() (int, error) {
+				return ops.TryOrderVals(nil, reflect.Value{}, reflect.Value{})
+			},
+			wantErr: ops.ErrInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderSameNamedTypes(t *testing.T) {
+	first := func() any {
+		type T struct{}
+		return T{}
+	}()
+	second := func() any {
+		type T struct{}
+		return T{}
+	}()
+	if reflect.TypeOf(first).String() != reflect.TypeOf(second).String() {
+		t.Fatal("expected the types to share a name")
+	}
+	c1, err := ops.TryOrder(nil, first, second)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	c2, err := ops.TryOrder(nil, second, first)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if c1 == 0 || c1 != -c2 {
+		t.Errorf("got %d and %d, want opposite nonzero results", c1, c2)
+	}
+}
@@ -0,0 +1,372 @@
+package ops
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func fieldStep(name string) string {
+	return "." + name
+}
+
+func indexStep(i int) string {
+	return fmt.Sprintf("[%d]", i)
+}
+
+func keyStep(env Env, k reflect.Value) string {
+	// Keys are kept on one line, so that paths are too.
+	str, err := TryFormatVals(WrapEnv(nestedEnv(env), FmtOptCompact()), k)
+	if err != nil {
+		str = "?"
+	}
+	return "[" + str + "]"
+}
+
+// The annotate functions are deferred by composite operations so that errors
+// raised while visiting a child record the path to that child.  They take
+// pointers to the loop state so that paths are only built when something goes
+// wrong.
+
+func annotateField(t reflect.Type, name *string) {
+	if r := recover(); r != nil {
+		var step string
+		if *name != "" {
+			step = fieldStep(*name)
+		}
+		panic(withPath(r, t, step))
+	}
+}
+
+func annotateIndex(t reflect.Type, i *int) {
+	if r := recover(); r != nil {
+		var step string
+		if *i >= 0 {
+			step = indexStep(*i)
+		}
+		panic(withPath(r, t, step))
+	}
+}
+
+func annotateKey(env Env, t reflect.Type, k *reflect.Value) {
+	if r := recover(); r != nil {
+		var step string
+		if k.IsValid() {
+			step = keyStep(env, *k)
+		}
+		panic(withPath(r, t, step))
+	}
+}
+
+func withPath(r any, t reflect.Type, step string) any {
+	err, ok := r.(error)
+	if !ok || step == "" || !isOpsError(err) {
+		return r
+	}
+	if pe, ok := err.(*PathError); ok {
+		return &PathError{Path: step + pe.Path, Type: t, Err: pe.Err}
+	}
+	return &PathError{Path: step, Type: t, Err: err}
+}
+
+// Path options register an Eq, Fmt, or Ord for the values at a path inside the
+// root value, like ".Items[*].Metadata.UpdatedAt".  A path is a sequence of
+// steps, each of which is a field (".Name"), a slice or array index ("[3]"), a
+// map key formatted as it would be in a DiffNode (`["key"]`), or "[*]", which
+// matches any index or key.  Pointers and interfaces don't add steps.
+//
+// Paths are tracked by the composite implementations (EqStruct, EqSlice,
+// EqMap, FmtStruct, FmtSlice, FmtMap, OrdStruct, and OrdSlice) as they visit
+// each child, and a path option takes precedence over the implementation that
+// the composite would otherwise use for that child.  If several path options
+// match, the most recently registered one wins.  Hash follows the Eq path
+// options, so that values that are equal still hash the same, except that it
+// only hashes the length of an unordered EqSlice that they apply inside.
+// Paths start at the value passed to Equal, Format, and so on, and they don't
+// apply to values that an operation works on along the way, like the map keys
+// that Format orders and Diff formats.
+
+var pathType = reflect.TypeFor[pathRule]()
+
+type eqPathTag struct{}
+type fmtPathTag struct{}
+type ordPathTag struct{}
+
+type pathPattern struct {
+	// The step as written, like ".Name" or "[3]".
+	text     string
+	field    bool
+	wildcard bool
+}
+
+type pathRule struct {
+	steps []pathPattern
+	impl  Val
+}
+
+func parsePath(path string) []pathPattern {
+	bad := func(msg string) {
+		panic(fmt.Errorf("%w: %q: %s", ErrBadPath, path, msg))
+	}
+	if path == "" {
+		bad("path is empty")
+	}
+	var steps []pathPattern
+	for rest := path; rest != ""; {
+		var n int
+		switch rest[0] {
+		case '.':
+			n = 1 + strings.IndexAny(rest[1:]+".", ".[")
+			if !token.IsIdentifier(rest[1:n]) {
+				bad(fmt.Sprintf("%q is not a field name", rest[1:n]))
+			}
+		case '[':
+			n = 1
+			// Quoted keys may contain "]".
+			if strings.HasPrefix(rest[1:], `"`) || strings.HasPrefix(rest[1:], "`") {
+				quoted, err := strconv.QuotedPrefix(rest[1:])
+				if err != nil {
+					bad("unterminated string")
+				}
+				n += len(quoted)
+			}
+			end := strings.IndexByte(rest[n:], ']')
+			if end < 0 {
+				bad("missing ]")
+			}
+			n += end + 1
+			if n == 2 {
+				bad("empty []")
+			}
+		default:
+			bad(fmt.Sprintf("step %q does not start with . or [", rest))
+		}
+		steps = append(steps, pathPattern{
+			text:     rest[:n],
+			field:    rest[0] == '.',
+			wildcard: rest[:n] == "[*]",
+		})
+		rest = rest[n:]
+	}
+	return steps
+}
+
+// pathOpt registers impl with tag for the values at path.  The rules for each
+// tag are kept in a single list, with the newest last, that each layer of the
+// Env extends.
+func pathOpt(tag Tag, path string, impl Val) Opt {
+	rule := &pathRule{steps: parsePath(path), impl: impl}
+	return OptFunc(func(env Env) {
+		var rules []*pathRule
+		if val, ok := env.Get(pathType, tag); ok {
+			rules = val.([]*pathRule)
+		}
+		env.SetAll(tag, append(slices.Clip(rules), rule))
+	})
+}
+
+// EqOptPath compares the values at path with eq.
+func EqOptPath(path string, eq Eq) Opt {
+	return pathOpt(eqPathTag{}, path, eq)
+}
+
+// FmtOptPath formats the values at path with fmt.
+func FmtOptPath(path string, fmt Fmt) Opt {
+	return pathOpt(fmtPathTag{}, path, fmt)
+}
+
+// OrdOptPath orders the values at path with ord.
+func OrdOptPath(path string, ord Ord) Opt {
+	return pathOpt(ordPathTag{}, path, ord)
+}
+
+// nestedEnv returns env for an operation that runs in the middle of another
+// one, like ordering map keys to format them.  The nested operation gets its
+// own state, so it doesn't count towards the other's limits, and path options
+// don't apply inside it, since its value isn't the root and paths can't refer
+// to anything inside map keys.
+func nestedEnv(env Env) Env {
+	if env == nil {
+		env = NewEnv()
+	}
+	s := &opState{pathsOff: true}
+	return WrapEnv(env, OptFunc(func(e Env) {
+		e.SetAll(stateTag{}, s)
+	}))
+}
+
+// fmtPathEnv returns env for formatting the value at the path made up of
+// steps, like those of a DiffNode, as though it were part of the root value.
+// If a path option registers a Fmt for the value itself, that's returned too.
+func fmtPathEnv(env Env, steps []string) (Env, Fmt) {
+	var rules []*pathRule
+	if env != nil {
+		if val, ok := env.Get(pathType, fmtPathTag{}); ok {
+			rules = val.([]*pathRule)
+		}
+	}
+	if len(rules) == 0 {
+		return env, nil
+	}
+	var impl Val
+	for depth, step := range steps {
+		var next []*pathRule
+		for _, rule := range rules {
+			p := rule.steps[depth]
+			if p.text != step && !(p.wildcard && !strings.HasPrefix(step, ".")) {
+				continue
+			}
+			switch {
+			case len(rule.steps) > depth+1:
+				next = append(next, rule)
+			case depth == len(steps)-1:
+				impl = rule.impl
+			}
+		}
+		rules = next
+	}
+	inside := make([]*pathRule, len(rules))
+	for i, rule := range rules {
+		inside[i] = &pathRule{steps: rule.steps[len(steps):], impl: rule.impl}
+	}
+	s := &opState{}
+	env = WrapEnv(env, OptFunc(func(e Env) {
+		e.SetAll(stateTag{}, s)
+		e.SetAll(fmtPathTag{}, inside)
+	}))
+	f, _ := impl.(Fmt)
+	return env, f
+}
+
+// pathStack follows the path to the value currently being visited.
+type pathStack struct {
+	// live[i] holds the rules whose first i steps match the path to the value
+	// i steps below the root, and that have more steps left to match.
+	live [][]*pathRule
+}
+
+// pathStep is a step to a child value.  Exactly one of the fields is set.
+type pathStep struct {
+	field string
+	index int
+	key   reflect.Value
+}
+
+func fieldPath(name string) pathStep {
+	return pathStep{field: name, index: -1}
+}
+
+func indexPath(i int) pathStep {
+	return pathStep{index: i}
+}
+
+func keyPath(k reflect.Value) pathStep {
+	return pathStep{index: -1, key: k}
+}
+
+// pathCursor is held by a composite value while it visits its children.  The
+// zero pathCursor is used when there are no path options.
+type pathCursor struct {
+	env   Env
+	stack *pathStack
+	depth int
+}
+
+// enterPaths returns a cursor for the value being visited, for the path
+// options registered with tag.
+func enterPaths(env Env, tag Tag) (Env, pathCursor) {
+	if env == nil {
+		return env, pathCursor{}
+	}
+	val, ok := env.Get(pathType, tag)
+	if !ok {
+		return env, pathCursor{}
+	}
+	env, s := withState(env)
+	if s.pathsOff {
+		return env, pathCursor{}
+	}
+	stack := s.paths[tag]
+	if stack == nil {
+		if s.paths == nil {
+			s.paths = make(map[Tag]*pathStack)
+		}
+		// The first composite value visited is the root.
+		stack = &pathStack{live: [][]*pathRule{val.([]*pathRule)}}
+		s.paths[tag] = stack
+	}
+	return env, pathCursor{env: env, stack: stack, depth: len(stack.live)}
+}
+
+// leave discards what the children of c's value pushed onto the stack.
+func (c pathCursor) leave() {
+	if c.stack != nil {
+		c.stack.live = c.stack.live[:c.depth]
+	}
+}
+
+// pending reports whether any path options might apply to the children of
+// c's value or to values inside them.
+func (c pathCursor) pending() bool {
+	return c.stack != nil && len(c.stack.live[c.depth-1]) > 0
+}
+
+// child moves the stack to the child of c's value at step, returning the
+// implementation registered for its path, if any.
+func (c pathCursor) child(step pathStep) (Val, bool) {
+	if c.stack == nil {
+		return nil, false
+	}
+	c.stack.live = c.stack.live[:c.depth]
+	var impl Val
+	var found bool
+	var next []*pathRule
+	var keyText string
+	for _, rule := range c.stack.live[c.depth-1] {
+		p := rule.steps[c.depth-1]
+		var match bool
+		switch {
+		case step.field != "":
+			match = p.field && p.text[1:] == step.field
+		case p.field:
+			match = false
+		case p.wildcard:
+			match = true
+		case step.index >= 0:
+			match = p.text == indexStep(step.index)
+		default:
+			if keyText == "" {
+				keyText = keyStep(c.env, step.key)
+			}
+			match = p.text == keyText
+		}
+		switch {
+		case !match:
+		case len(rule.steps) == c.depth:
+			impl, found = rule.impl, true
+		default:
+			next = append(next, rule)
+		}
+	}
+	c.stack.live = append(c.stack.live, next)
+	return impl, found && impl != nil
+}
+
+// skip moves the stack to a value inside c's value, like a map key, that
+// paths can't refer to.
+func (c pathCursor) skip() {
+	if c.stack != nil {
+		c.stack.live = append(c.stack.live[:c.depth], nil)
+	}
+}
+
+// pathChild is like child, but returns def if no path option applies.
+func pathChild[T any](c pathCursor, step pathStep, def T) T {
+	if impl, ok := c.child(step); ok {
+		return impl.(T)
+	}
+	return def
+}
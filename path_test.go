@@ -0,0 +1,191 @@
+package ops_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+type pathMetadata struct {
+	Owner     string
+	UpdatedAt int
+}
+
+type pathItem struct {
+	Name     string
+	Metadata *pathMetadata
+}
+
+type pathList struct {
+	Items  []pathItem
+	Labels map[string]int
+}
+
+func TestPathOpts(t *testing.T) {
+	list := func(updated1, updated2 int) pathList {
+		return pathList{
+			Items: []pathItem{
+				{Name: "a", Metadata: &pathMetadata{Owner: "x", UpdatedAt: updated1}},
+				{Name: "b", Metadata: &pathMetadata{Owner: "y", UpdatedAt: updated2}},
+			},
+			Labels: map[string]int{"env": 1, "rev": 2},
+		}
+	}
+	ignoreUpdated := ops.EqOptPath(".Items[*].Metadata.UpdatedAt", ops.EqTrue{})
+
+	t.Run("Eq", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ignoreUpdated)
+		if !ops.Equal(env, list(1, 2), list(3, 4)) {
+			t.Error("expected UpdatedAt to be ignored")
+		}
+		l := list(1, 2)
+		l.Items[1].Metadata.Owner = "z"
+		if ops.Equal(env, list(1, 2), l) {
+			t.Error("expected Owner to be compared")
+		}
+		if ops.Equal(ops.NewEnv(), list(1, 2), list(3, 4)) {
+			t.Error("expected UpdatedAt to be compared without the option")
+		}
+	})
+
+	t.Run("Index", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOptPath(".Items[1].Metadata.UpdatedAt", ops.EqTrue{}))
+		if !ops.Equal(env, list(1, 2), list(1, 4)) {
+			t.Error("expected UpdatedAt of item 1 to be ignored")
+		}
+		if ops.Equal(env, list(1, 2), list(3, 2)) {
+			t.Error("expected UpdatedAt of item 0 to be compared")
+		}
+	})
+
+	t.Run("Map Key", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOptPath(`.Labels["rev"]`, ops.EqTrue{}))
+		l := list(1, 2)
+		l.Labels["rev"] = 3
+		if !ops.Equal(env, list(1, 2), l) {
+			t.Error(`expected Labels["rev"] to be ignored`)
+		}
+		l.Labels["env"] = 3
+		if ops.Equal(env, list(1, 2), l) {
+			t.Error(`expected Labels["env"] to be compared`)
+		}
+	})
+
+	t.Run("Unordered", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(),
+			ops.EqOpt(reflect.TypeFor[[]pathItem](), ops.EqSlice{Unordered: true}),
+			ignoreUpdated)
+		l := list(3, 4)
+		l.Items[0], l.Items[1] = l.Items[1], l.Items[0]
+		if !ops.Equal(env, list(1, 2), l) {
+			t.Error("expected reordered items to be equal")
+		}
+	})
+
+	t.Run("Newest Wins", func(t *testing.T) {
+		env := ops.WrapEnv(ops.WrapEnv(ops.NewEnv(), ignoreUpdated),
+			ops.EqOptPath(".Items[*].Metadata.UpdatedAt", ops.EqDeep{}))
+		if ops.Equal(env, list(1, 2), list(3, 4)) {
+			t.Error("expected the newer option to win")
+		}
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ignoreUpdated, ops.EqOptPath(`.Labels["rev"]`, ops.EqTrue{}))
+		l := list(3, 4)
+		l.Labels["rev"] = 5
+		if ops.Hash(env, list(1, 2)) != ops.Hash(env, l) {
+			t.Error("expected values that are equal to hash the same")
+		}
+		unordered := ops.WrapEnv(env, ops.EqOpt(reflect.TypeFor[[]pathItem](), ops.EqSlice{Unordered: true}))
+		l.Items[0], l.Items[1] = l.Items[1], l.Items[0]
+		if ops.Hash(unordered, list(1, 2)) != ops.Hash(unordered, l) {
+			t.Error("expected reordered values that are equal to hash the same")
+		}
+		set := ops.NewSet[pathList](unordered)
+		set.Add(list(1, 2))
+		set.Add(l)
+		if set.Len() != 1 {
+			t.Errorf("got %d elements, want 1", set.Len())
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ignoreUpdated)
+		l := list(3, 4)
+		l.Items[0].Name = "c"
+		l.Labels["env"] = 5
+		got := ops.Diff(env, list(1, 2), l).String()
+		want := ".Items[0].Name: \"a\" != \"c\"\n.Labels[\"env\"]: 1 != 5"
+		if got != want {
+			t.Errorf("got diff:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Fmt", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(),
+			ops.FmtOptCompact(),
+			ops.FmtOptPath(".Items[*].Metadata", ops.FmtElide{}),
+			ops.FmtOptPath(`.Labels["rev"]`, ops.FmtElide{}))
+		got := ops.Format(env, list(1, 2))
+		want := `ops_test.pathList{Items: []ops_test.pathItem{ops_test.pathItem{Name: "a", Metadata: *ops_test.pathMetadata(...)}, ` +
+			`ops_test.pathItem{Name: "b", Metadata: *ops_test.pathMetadata(...)}}, Labels: map[string]int{"env": 1, "rev": int(...)}}`
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Ord", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.OrdOptPath(".Items[*].Name", ops.OrdReverse{}))
+		type items struct {
+			Items []pathItem
+		}
+		a := items{Items: []pathItem{{Name: "a"}}}
+		b := items{Items: []pathItem{{Name: "b"}}}
+		if got := ops.Order(env, a, b); got != 1 {
+			t.Errorf("got %d, want 1", got)
+		}
+		if got := ops.Order(ops.NewEnv(), a, b); got != -1 {
+			t.Errorf("got %d without the option, want -1", got)
+		}
+	})
+
+	t.Run("Nested Operations", func(t *testing.T) {
+		type key struct {
+			X int
+		}
+		type root struct {
+			M map[key]int
+		}
+		r1 := root{M: map[key]int{{X: 1}: 1, {X: 2}: 2}}
+		r2 := root{M: map[key]int{{X: 1}: 3, {X: 2}: 2}}
+
+		// Keys are ordered to format them, but they aren't the root.
+		reversed := ops.WrapEnv(ops.NewEnv(), ops.OrdOptPath(".X", ops.OrdReverse{}))
+		if got, want := ops.Format(reversed, r1), ops.Format(ops.NewEnv(), r1); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		// Keys are formatted to describe paths, but they aren't the root.
+		elided := ops.WrapEnv(ops.NewEnv(), ops.FmtOptPath(".X", ops.FmtElide{}))
+		if got, want := ops.Diff(elided, r1, r2).String(), ops.Diff(ops.NewEnv(), r1, r2).String(); got != want {
+			t.Errorf("got diff:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Bad Paths", func(t *testing.T) {
+		for _, path := range []string{"", "Items", ".", ".1x", "[", "[]", `["a]`, ".Items[*]x"} {
+			t.Run(path, func(t *testing.T) {
+				defer func() {
+					err, _ := recover().(error)
+					if !errors.Is(err, ops.ErrBadPath) {
+						t.Errorf("got panic %v, want %v", err, ops.ErrBadPath)
+					}
+				}()
+				ops.EqOptPath(path, ops.EqTrue{})
+			})
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package ops
+
+import (
+	"hash/maphash"
+	"iter"
+	"math/bits"
+	"slices"
+)
+
+// pmap is a persistent hash map, used by frozen Envs.  with returns an
+// updated copy that shares all but the O(log n) nodes on the path to the key
+// with the original, so that many slightly different copies of a large map
+// are cheap to make.  The zero pmap is empty, and a pmap is never modified
+// once made.
+type pmap[K comparable, V any] struct {
+	root *pnode[K, V]
+}
+
+var pmapSeed = maphash.MakeSeed()
+
+// Each level of the trie picks one of 1<<pmapBits slots using the next
+// pmapBits bits of a key's hash.
+const (
+	pmapBits = 5
+	pmapMask = 1<<pmapBits - 1
+)
+
+// pnode is a node of the trie.  Only the slots in use are stored.
+type pnode[K comparable, V any] struct {
+	// Bit i is set if slot i is in use.
+	used  uint32
+	slots []pslot[K, V]
+}
+
+// pslot holds either a child node or the entries for a single hash.  Keys
+// with the same hash share a slot, however deep the trie is.
+type pslot[K comparable, V any] struct {
+	child   *pnode[K, V]
+	hash    uint64
+	entries []pentry[K, V]
+}
+
+type pentry[K comparable, V any] struct {
+	key K
+	val V
+}
+
+func pmapSlot(hash uint64, shift uint) uint32 {
+	return 1 << (hash >> shift & pmapMask)
+}
+
+// index returns where the slot for bit is, or would go, in n.slots.
+func (n *pnode[K, V]) index(bit uint32) int {
+	return bits.OnesCount32(n.used & (bit - 1))
+}
+
+func (m pmap[K, V]) get(key K) (V, bool) {
+	hash := maphash.Comparable(pmapSeed, key)
+	n := m.root
+	for shift := uint(0); n != nil; shift += pmapBits {
+		bit := pmapSlot(hash, shift)
+		if n.used&bit == 0 {
+			break
+		}
+		s := &n.slots[n.index(bit)]
+		if s.child != nil {
+			n = s.child
+			continue
+		}
+		if s.hash == hash {
+			for _, e := range s.entries {
+				if e.key == key {
+					return e.val, true
+				}
+			}
+		}
+		break
+	}
+	var zero V
+	return zero, false
+}
+
+// with returns a copy of m with key set to val.
+func (m pmap[K, V]) with(key K, val V) pmap[K, V] {
+	hash := maphash.Comparable(pmapSeed, key)
+	return pmap[K, V]{root: m.root.with(0, hash, pentry[K, V]{key, val})}
+}
+
+// with returns a copy of n, which may be nil, with e added to it.
+func (n *pnode[K, V]) with(shift uint, hash uint64, e pentry[K, V]) *pnode[K, V] {
+	bit := pmapSlot(hash, shift)
+	if n == nil {
+		return &pnode[K, V]{used: bit, slots: []pslot[K, V]{{hash: hash, entries: []pentry[K, V]{e}}}}
+	}
+	i := n.index(bit)
+	if n.used&bit == 0 {
+		return &pnode[K, V]{
+			used:  n.used | bit,
+			slots: slices.Insert(slices.Clip(n.slots), i, pslot[K, V]{hash: hash, entries: []pentry[K, V]{e}}),
+		}
+	}
+	out := &pnode[K, V]{used: n.used, slots: slices.Clone(n.slots)}
+	s := &out.slots[i]
+	switch {
+	case s.child != nil:
+		s.child = s.child.with(shift+pmapBits, hash, e)
+	case s.hash == hash:
+		j := slices.IndexFunc(s.entries, func(old pentry[K, V]) bool {
+			return old.key == e.key
+		})
+		if j < 0 {
+			s.entries = append(slices.Clip(s.entries), e)
+		} else {
+			s.entries = slices.Clone(s.entries)
+			s.entries[j] = e
+		}
+	default:
+		// Move the entries that were here down a level, where their hash
+		// and the new one might pick different slots.
+		below := &pnode[K, V]{used: pmapSlot(s.hash, shift+pmapBits), slots: []pslot[K, V]{*s}}
+		*s = pslot[K, V]{child: below.with(shift+pmapBits, hash, e)}
+	}
+	return out
+}
+
+// all yields the entries of m in no particular order.
+func (m pmap[K, V]) all() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.root.all(yield)
+	}
+}
+
+func (n *pnode[K, V]) all(yield func(K, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	for _, s := range n.slots {
+		if s.child != nil {
+			if !s.child.all(yield) {
+				return false
+			}
+			continue
+		}
+		for _, e := range s.entries {
+			if !yield(e.key, e.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
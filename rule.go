@@ -0,0 +1,229 @@
+package ops
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Between Set, which registers a value for one type, and SetAll, which
+// registers it for every type, values can be registered for every type that
+// matches a rule by wrapping an Opt in KindOpt, PredOpt, or ImplementsOpt.
+//
+// Within a single layer of an Env, a value registered with Set takes
+// precedence over one registered for a predicate (with PredOpt or
+// ImplementsOpt), which takes precedence over one registered for a kind.
+// Among rules of the same sort, the most recent one wins.  As with Set, a
+// rule replaces any earlier SetAll in the same layer, and a SetAll replaces
+// all earlier registrations.  Layers added by WrapEnv are consulted before
+// their parents, as always.
+
+type envRule struct {
+	desc string
+	// Predicate rules take precedence over kind rules.
+	pred  bool
+	match func(reflect.Type) bool
+}
+
+// and returns a rule that matches types that both r and other match.
+func (r *envRule) and(other *envRule) *envRule {
+	return &envRule{
+		desc: r.desc + " and " + other.desc,
+		pred: r.pred || other.pred,
+		match: func(t reflect.Type) bool {
+			return r.match(t) && other.match(t)
+		},
+	}
+}
+
+type ruleVal struct {
+	rule *envRule
+	val  Val
+}
+
+type ruleResult struct {
+	val Val
+	ok  bool
+}
+
+// ruleTypeToVal holds the values registered in one layer for a tag once any
+// rule has been registered for it.
+type ruleTypeToVal struct {
+	types mapTypeToVal
+	// In order of precedence.
+	rules []ruleVal
+	// Matching rules against a type can be slow, so the results are cached.
+	cache *sync.Map // reflect.Type -> ruleResult
+}
+
+func newRuleTypeToVal(types mapTypeToVal) *ruleTypeToVal {
+	if types == nil {
+		types = make(mapTypeToVal)
+	}
+	return &ruleTypeToVal{types: types, cache: &sync.Map{}}
+}
+
+func (r *ruleTypeToVal) Get(typ reflect.Type) (Val, bool) {
+	if val, ok := r.types[typ]; ok {
+		return val, true
+	}
+	if cached, ok := r.cache.Load(typ); ok {
+		result := cached.(ruleResult)
+		return result.val, result.ok
+	}
+	var result ruleResult
+	if rv, ok := r.match(typ); ok {
+		result = ruleResult{val: rv.val, ok: true}
+	}
+	r.cache.Store(typ, result)
+	return result.val, result.ok
+}
+
+func (r *ruleTypeToVal) match(typ reflect.Type) (ruleVal, bool) {
+	for _, rv := range r.rules {
+		if rv.rule.match(typ) {
+			return rv, true
+		}
+	}
+	return ruleVal{}, false
+}
+
+func (r *ruleTypeToVal) add(rule *envRule, val Val) {
+	// Newer rules go before older ones of the same sort.
+	idx := 0
+	if !rule.pred {
+		idx = slices.IndexFunc(r.rules, func(rv ruleVal) bool {
+			return !rv.rule.pred
+		})
+		if idx < 0 {
+			idx = len(r.rules)
+		}
+	}
+	r.rules = slices.Insert(r.rules, idx, ruleVal{rule: rule, val: val})
+	r.cache = &sync.Map{}
+}
+
+func (r *ruleTypeToVal) clone() *ruleTypeToVal {
+	out := newRuleTypeToVal(maps.Clone(r.types))
+	out.rules = slices.Clone(r.rules)
+	return out
+}
+
+// ruleSetter is implemented by Envs that can store rules.
+type ruleSetter interface {
+	setRule(*envRule, Tag, Val)
+}
+
+func (e *mapEnv) setRule(rule *envRule, tag Tag, val Val) {
+	if tag == nil {
+		panic(ErrNilTag)
+	}
+	if *e == nil {
+		*e = make(map[Tag]typeToVal)
+	}
+	var rules *ruleTypeToVal
+	switch ttv := (*e)[tag].(type) {
+	case *ruleTypeToVal:
+		rules = ttv
+	case mapTypeToVal:
+		rules = newRuleTypeToVal(ttv)
+	default:
+		rules = newRuleTypeToVal(nil)
+	}
+	rules.add(rule, val)
+	(*e)[tag] = rules
+}
+
+func (e *wrappedEnv) setRule(rule *envRule, tag Tag, val Val) {
+	e.data.setRule(rule, tag, val)
+}
+
+func (e *syncEnv) setRule(rule *envRule, tag Tag, val Val) {
+	e.update(func(data *mapEnv) {
+		data.setRule(rule, tag, val)
+	})
+}
+
+func (e *frozenEnv) setRule(*envRule, Tag, Val) {
+	panic(ErrFrozen)
+}
+
+// ruleEnv is passed to an Opt to turn its SetAll calls into rules.
+type ruleEnv struct {
+	env  Env
+	rule *envRule
+}
+
+func (e *ruleEnv) Set(typ reflect.Type, tag Tag, val Val) {
+	if typ == nil {
+		panic(ErrNilType)
+	}
+	if e.rule.match(typ) {
+		e.env.Set(typ, tag, val)
+	}
+}
+
+func (e *ruleEnv) SetAll(tag Tag, val Val) {
+	e.setRule(e.rule, tag, val)
+}
+
+func (e *ruleEnv) Get(typ reflect.Type, tag Tag) (Val, bool) {
+	return e.env.Get(typ, tag)
+}
+
+func (e *ruleEnv) setRule(rule *envRule, tag Tag, val Val) {
+	rs, ok := e.env.(ruleSetter)
+	if !ok {
+		panic(fmt.Errorf("%w: %T does not support rules", ErrInvalid, e.env))
+	}
+	rs.setRule(rule, tag, val)
+}
+
+func ruleOpt(opt Opt, rule *envRule) Opt {
+	return OptFunc(func(env Env) {
+		// The Opt can be applied many times, so rule itself isn't narrowed.
+		r := rule
+		if re, ok := env.(*ruleEnv); ok {
+			r = re.rule.and(rule)
+			env = re.env
+		}
+		opt.Update(&ruleEnv{env: env, rule: r})
+	})
+}
+
+// KindOpt applies opt only to types of the given kinds: its SetAll calls
+// register values for just those types, and its Set calls for other types
+// are dropped.
+func KindOpt(opt Opt, kinds ...reflect.Kind) Opt {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.String()
+	}
+	return ruleOpt(opt, &envRule{
+		desc: "kind " + strings.Join(names, "|"),
+		match: func(t reflect.Type) bool {
+			return slices.Contains(kinds, t.Kind())
+		},
+	})
+}
+
+// PredOpt is like KindOpt, but applies opt to the types for which pred
+// returns true.  pred is described by desc in EnvLayers and Explain.
+func PredOpt(opt Opt, desc string, pred func(reflect.Type) bool) Opt {
+	return ruleOpt(opt, &envRule{desc: desc, pred: true, match: pred})
+}
+
+// ImplementsOpt is like KindOpt, but applies opt to the types that implement
+// the interface I.
+func ImplementsOpt[I any](opt Opt) Opt {
+	iface := reflect.TypeFor[I]()
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Errorf("%w: %s is not an interface", ErrWrongType, typeName(iface)))
+	}
+	return PredOpt(opt, "implements "+typeName(iface), func(t reflect.Type) bool {
+		return t.Implements(iface)
+	})
+}
@@ -0,0 +1,211 @@
+package ops_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+type meters float64
+
+type notFound struct{}
+
+func (notFound) Error() string {
+	return "not found"
+}
+
+func TestRules(t *testing.T) {
+	t.Run("Kind", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(),
+			ops.KindOpt(ops.EqOptAll(ops.EqFloat{AbsTol: 0.01}), reflect.Float32, reflect.Float64))
+		type Reading struct {
+			Dist  meters
+			Temp  float32
+			Count int
+		}
+		r1 := Reading{Dist: 1, Temp: 20, Count: 3}
+		r2 := Reading{Dist: 1.001, Temp: 20.001, Count: 3}
+		if !ops.Equal(env, r1, r2) {
+			t.Errorf("expected %v and %v to be equal", r1, r2)
+		}
+		r2.Count = 4
+		if ops.Equal(env, r1, r2) {
+			t.Errorf("expected %v and %v to differ", r1, r2)
+		}
+	})
+
+	t.Run("Func", func(t *testing.T) {
+		type Handler struct {
+			Name string
+			Fn   func()
+		}
+		env := ops.WrapEnv(ops.NewEnv(), ops.KindOpt(ops.FmtOptAll(ops.FmtElide{}), reflect.Func))
+		got := ops.Format(env, Handler{Name: "h", Fn: func() {}})
+		want := ops.Format(ops.WrapEnv(ops.NewEnv(), ops.FmtOpt(reflect.TypeFor[func()](), ops.FmtElide{})), Handler{Name: "h"})
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Implements", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.ImplementsOpt[error](ops.FmtOptAll(ops.FmtStringer{})))
+		errType := reflect.TypeFor[notFound]()
+		explanation, ok := ops.Explain(env, errType, ops.EnvLayers(env)[0].Entries[0].Tag)
+		if !ok {
+			t.Fatal("expected a value for notFound")
+		}
+		want := fmt.Sprintf("layer 0 (WrapEnv): SetAll(ops.fmtTag) for implements error = %v", ops.FmtStringer{})
+		if got := explanation.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if _, ok := ops.Explain(env, reflect.TypeFor[int](), ops.EnvLayers(env)[0].Entries[0].Tag); ok {
+			t.Error("expected no value for int")
+		}
+	})
+
+	t.Run("Precedence", func(t *testing.T) {
+		type myInt int
+		intType := reflect.TypeFor[int]()
+		myIntType := reflect.TypeFor[myInt]()
+		isMyInt := func(t reflect.Type) bool { return t == myIntType }
+		set := func(tag ops.Tag, val ops.Val) ops.Opt {
+			return ops.OptFunc(func(env ops.Env) { env.SetAll(tag, val) })
+		}
+
+		env := ops.NewEnv()
+		ops.PredOpt(set("tag", "pred"), "myInt", isMyInt).Update(env)
+		ops.KindOpt(set("tag", "kind"), reflect.Int).Update(env)
+		tests := []struct {
+			name string
+			typ  reflect.Type
+			want string
+		}{
+			{name: "Kind", typ: intType, want: "kind"},
+			{name: "Pred Over Kind", typ: myIntType, want: "pred"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got, _ := env.Get(tt.typ, "tag"); got != tt.want {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		env.Set(myIntType, "tag", "set")
+		if got, _ := env.Get(myIntType, "tag"); got != "set" {
+			t.Errorf("expected Set to win over rules, got %v", got)
+		}
+		ops.KindOpt(set("tag", "newer kind"), reflect.Int).Update(env)
+		if got, _ := env.Get(intType, "tag"); got != "newer kind" {
+			t.Errorf("expected the newer rule to win, got %v", got)
+		}
+		if got, _ := env.Get(reflect.TypeFor[string](), "tag"); got != nil {
+			t.Errorf("expected no value for string, got %v", got)
+		}
+
+		env.SetAll("tag", "all")
+		if got, _ := env.Get(myIntType, "tag"); got != "all" {
+			t.Errorf("expected SetAll to replace rules, got %v", got)
+		}
+		ops.KindOpt(set("tag", "kind"), reflect.Int).Update(env)
+		if got, _ := env.Get(intType, "tag"); got != "kind" {
+			t.Errorf("expected a rule to replace SetAll, got %v", got)
+		}
+		if _, ok := env.Get(reflect.TypeFor[string](), "tag"); ok {
+			t.Error("expected a rule to replace SetAll for other types")
+		}
+
+		child := ops.WrapEnv(env, ops.PredOpt(set("tag", "child"), "myInt", isMyInt))
+		if got, _ := child.Get(myIntType, "tag"); got != "child" {
+			t.Errorf("expected the child layer to win, got %v", got)
+		}
+		if got, _ := child.Get(intType, "tag"); got != "kind" {
+			t.Errorf("expected the parent layer to be consulted, got %v", got)
+		}
+
+		frozen := ops.Freeze(child)
+		for _, typ := range []reflect.Type{intType, myIntType} {
+			got, _ := frozen.Get(typ, "tag")
+			want, _ := child.Get(typ, "tag")
+			if got != want {
+				t.Errorf("frozen env got %v for %s, want %v", got, typ, want)
+			}
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		set := ops.OptFunc(func(env ops.Env) {
+			env.SetAll("tag", "val")
+			env.Set(reflect.TypeFor[string](), "other", "dropped")
+		})
+		env := ops.NewSyncEnv()
+		ops.KindOpt(ops.PredOpt(set, "named", func(t reflect.Type) bool {
+			return t.PkgPath() != ""
+		}), reflect.Float64).Update(env)
+		if _, ok := env.Get(reflect.TypeFor[meters](), "tag"); !ok {
+			t.Error("expected a value for meters")
+		}
+		if _, ok := env.Get(reflect.TypeFor[float64](), "tag"); ok {
+			t.Error("expected no value for float64")
+		}
+		if _, ok := env.Get(reflect.TypeFor[string](), "other"); ok {
+			t.Error("expected Set for a type that doesn't match to be dropped")
+		}
+		entries := ops.EnvLayers(env)[0].Entries
+		if len(entries) != 1 || entries[0].String() != `SetAll("tag") for kind float64 and named = val` {
+			t.Errorf("got entries %v", entries)
+		}
+	})
+
+	t.Run("Reused", func(t *testing.T) {
+		inner := ops.KindOpt(ops.EqOptAll(ops.EqTrue{}), reflect.Int, reflect.String)
+		ops.KindOpt(inner, reflect.Int).Update(ops.NewEnv())
+		env := ops.WrapEnv(ops.NewEnv(), inner)
+		if !ops.Equal(env, "a", "b") {
+			t.Error("expected strings to be equal after inner was nested in another rule")
+		}
+		entries := ops.EnvLayers(env)[0].Entries
+		if len(entries) != 1 || entries[0].String() != fmt.Sprintf("SetAll(ops.eqTag) for kind int|string = %v", ops.EqTrue{}) {
+			t.Errorf("got entries %v", entries)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		opt := ops.KindOpt(ops.FmtOptAll(ops.FmtElide{}), reflect.Func)
+		tests := []struct {
+			name string
+			f    func()
+			want error
+		}{
+			{
+				name: "Frozen",
+				f:    func() { opt.Update(ops.Freeze(ops.NewEnv())) },
+				want: ops.ErrFrozen,
+			},
+			{
+				name: "Unsupported Env",
+				f:    func() { opt.Update(struct{ ops.Env }{ops.NewEnv()}) },
+				want: ops.ErrInvalid,
+			},
+			{
+				name: "Not An Interface",
+				f:    func() { ops.ImplementsOpt[int](opt) },
+				want: ops.ErrWrongType,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				defer func() {
+					err, _ := recover().(error)
+					if !errors.Is(err, tt.want) {
+						t.Errorf("got panic %v, want %v", err, tt.want)
+					}
+				}()
+				tt.f()
+			})
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package ops
+
+import "reflect"
+
+// Operations that need to carry state down through a recursive walk (like the
+// set of references currently being visited) store it in the Env, so that it
+// survives calls through user-supplied Eq, Fmt, and Ord implementations.
+type stateTag struct{}
+
+var stateType = reflect.TypeFor[opState]()
+
+type opState struct {
+	eq   visits
+	diff visits
+	fmt  visits
+	ord  visits
+
+	hashDepth int
+	clones    map[visitKey]reflect.Value
+	fmtLimits *fmtLimitFrame
+	paths     map[Tag]*pathStack
+	// Set for operations nested inside another one; see nestedEnv.
+	pathsOff bool
+}
+
+func withState(env Env) (Env, *opState) {
+	if env == nil {
+		env = NewEnv()
+	} else if val, ok := env.Get(stateType, stateTag{}); ok {
+		return env, val.(*opState)
+	}
+	s := &opState{}
+	return WrapEnv(env, OptFunc(func(e Env) {
+		e.SetAll(stateTag{}, s)
+	})), s
+}
+
+type visitKey struct {
+	typ        reflect.Type
+	ptr1, ptr2 uintptr
+	len        int
+}
+
+func refOf(v reflect.Value) (uintptr, int, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return v.Pointer(), 0, v.Pointer() != 0
+	case reflect.Slice:
+		return v.Pointer(), v.Len(), v.Pointer() != 0 && v.Len() > 0
+	default:
+		return 0, 0, false
+	}
+}
+
+func visitKeyFor(v1, v2 reflect.Value) (visitKey, bool) {
+	ptr1, len1, ok1 := refOf(v1)
+	ptr2, _, ok2 := refOf(v2)
+	if !ok1 || !ok2 {
+		return visitKey{}, false
+	}
+	return visitKey{typ: v1.Type(), ptr1: ptr1, ptr2: ptr2, len: len1}, true
+}
+
+// visits tracks the references on the path from the root value to the value
+// currently being visited.
+type visits map[visitKey]int
+
+// enter returns false if v1 and v2 are already being visited further up the
+// current path, along with the 1-based position on the path where they were
+// first entered.
+func (vs *visits) enter(v1, v2 reflect.Value) (int, bool) {
+	key, ok := visitKeyFor(v1, v2)
+	if !ok {
+		return 0, true
+	}
+	if pos, found := (*vs)[key]; found {
+		return pos, false
+	}
+	if *vs == nil {
+		*vs = make(visits)
+	}
+	(*vs)[key] = len(*vs) + 1
+	return 0, true
+}
+
+func (vs *visits) leave(v1, v2 reflect.Value) {
+	if key, ok := visitKeyFor(v1, v2); ok {
+		delete(*vs, key)
+	}
+}
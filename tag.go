@@ -0,0 +1,97 @@
+package ops
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Struct fields can configure how EqStruct, FmtStruct, and OrdStruct treat
+// them with an "ops" tag, like `ops:"eq=ignore,fmt=elide,ord=desc"`.  Entries
+// in the Fields map of those types take precedence over tags.
+//
+// Supported directives:
+//
+//	eq=ignore   compare the field with EqTrue
+//	fmt=elide   format the field with FmtElide
+//	ord=ignore  order the field with OrdNone
+//	ord=desc    order the field with OrdReverse
+const tagKey = "ops"
+
+type fieldTag struct {
+	eq  Eq
+	fmt Fmt
+	ord Ord
+}
+
+type structTags struct {
+	// Indexed by field number, or nil if no field of the struct is tagged.
+	fields []fieldTag
+	err    error
+}
+
+var structTagCache sync.Map // reflect.Type -> *structTags
+
+// tagsFor returns the parsed tag of each field of the struct type t, panicking
+// if any of them are malformed.
+func tagsFor(t reflect.Type) []fieldTag {
+	cached, ok := structTagCache.Load(t)
+	if !ok {
+		cached, _ = structTagCache.LoadOrStore(t, parseStructTags(t))
+	}
+	tags := cached.(*structTags)
+	if tags.err != nil {
+		panic(tags.err)
+	}
+	return tags.fields
+}
+
+func parseStructTags(t reflect.Type) *structTags {
+	var fields []fieldTag
+	for fNum := range t.NumField() {
+		f := t.Field(fNum)
+		tag, ok := f.Tag.Lookup(tagKey)
+		if !ok {
+			continue
+		}
+		parsed, err := parseFieldTag(tag)
+		if err != nil {
+			return &structTags{err: fmt.Errorf("%w: field %s of %s: %w", ErrBadTag, f.Name, typeName(t), err)}
+		}
+		if fields == nil {
+			fields = make([]fieldTag, t.NumField())
+		}
+		fields[fNum] = parsed
+	}
+	return &structTags{fields: fields}
+}
+
+func parseFieldTag(tag string) (fieldTag, error) {
+	var ft fieldTag
+	seen := map[string]bool{}
+	for directive := range strings.SplitSeq(tag, ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		op, arg, _ := strings.Cut(directive, "=")
+		if seen[op] {
+			return fieldTag{}, fmt.Errorf("duplicate directive %q", directive)
+		}
+		seen[op] = true
+		switch {
+		case op == "eq" && arg == "ignore":
+			ft.eq = EqTrue{}
+		case op == "fmt" && arg == "elide":
+			ft.fmt = FmtElide{}
+		case op == "ord" && arg == "ignore":
+			ft.ord = OrdNone{}
+		case op == "ord" && arg == "desc":
+			ft.ord = OrdReverse{}
+		default:
+			return fieldTag{}, fmt.Errorf("unknown directive %q", directive)
+		}
+	}
+	return ft, nil
+}
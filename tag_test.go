@@ -0,0 +1,78 @@
+package ops_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/krelinga/go-ops"
+)
+
+func TestStructTags(t *testing.T) {
+	type Entry struct {
+		Name     string
+		Priority int    `ops:"ord=desc"`
+		Updated  int64  `ops:"eq=ignore,fmt=elide,ord=ignore"`
+		note     string `ops:"eq=ignore,ord=ignore"`
+	}
+
+	t.Run("Eq", func(t *testing.T) {
+		e1 := Entry{Name: "a", Updated: 1, note: "x"}
+		e2 := Entry{Name: "a", Updated: 2, note: "y"}
+		if !ops.Equal(nil, e1, e2) {
+			t.Errorf("ignored fields were compared:\n%s", ops.Diff(nil, e1, e2))
+		}
+		if ops.Hash(nil, e1) != ops.Hash(nil, e2) {
+			t.Error("equal values have different hashes")
+		}
+	})
+
+	t.Run("Fmt", func(t *testing.T) {
+		want := `ops_test.Entry{
+  Name: "a",
+  Priority: 1,
+  Updated: int64(...),
+  ...
+}`
+		if got := ops.Format(nil, Entry{Name: "a", Priority: 1, Updated: 5}); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Ord", func(t *testing.T) {
+		if got := ops.Order(nil, Entry{Priority: 2, Updated: 1}, Entry{Priority: 1, Updated: 2}); got != -1 {
+			t.Errorf("got %d, want -1", got)
+		}
+		if got := ops.Order(nil, Entry{Updated: 1}, Entry{Updated: 2}); got != 0 {
+			t.Errorf("got %d, want 0", got)
+		}
+	})
+
+	t.Run("Explicit Fields Win", func(t *testing.T) {
+		env := ops.WrapEnv(ops.NewEnv(), ops.EqOpt(reflect.TypeFor[Entry](), ops.EqStruct{
+			Fields: map[ops.Field]ops.Eq{
+				ops.NamedField("Updated"): ops.EqDeep{},
+			},
+		}))
+		if ops.Equal(env, Entry{Updated: 1}, Entry{Updated: 2}) {
+			t.Error("explicit field entry did not override tag")
+		}
+	})
+
+	t.Run("Unknown Directive", func(t *testing.T) {
+		type Bad struct {
+			Name string `ops:"eq=sometimes"`
+		}
+		type Outer struct {
+			Inner Bad
+		}
+		_, err := ops.TryEqual(nil, Outer{}, Outer{})
+		if !errors.Is(err, ops.ErrBadTag) {
+			t.Fatalf("got error %v, want %v", err, ops.ErrBadTag)
+		}
+		want := `ops_test.Outer.Inner: invalid struct tag: field Name of ops_test.Bad: unknown directive "eq=sometimes"`
+		if err.Error() != want {
+			t.Errorf("got error %q, want %q", err, want)
+		}
+	})
+}